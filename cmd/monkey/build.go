@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hellozee/monkey/lib/compiler"
+	"github.com/hellozee/monkey/lib/diagnostic"
+	"github.com/hellozee/monkey/lib/mkc"
+	"github.com/hellozee/monkey/lib/optimizer"
+	"github.com/hellozee/monkey/lib/parser"
+	"github.com/hellozee/monkey/lib/peephole"
+)
+
+// build compiles the script at path to output, which defaults to path
+// with its extension replaced by .mkc.
+func build(path, output string, level optimizer.Level) int {
+	bytecode, source, ok := compilescript(path, level)
+	if !ok {
+		return 1
+	}
+
+	if output == "" {
+		output = strings.TrimSuffix(path, filepath.Ext(path)) + ".mkc"
+	}
+
+	var buf bytes.Buffer
+	if err := mkc.Write(&buf, &mkc.File{Source: path, Text: source, Bytecode: bytecode}); err != nil {
+		fmt.Fprintf(os.Stderr, "monkey: %s: %s\n", path, err)
+		return 1
+	}
+
+	if err := os.WriteFile(output, buf.Bytes(), 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "monkey: %s\n", err)
+		return 1
+	}
+	return 0
+}
+
+// compilescript optimizes and compiles the script at path and returns its
+// bytecode and source, errors are reported on stderr.
+func compilescript(path string, level optimizer.Level) (*compiler.Bytecode, string, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "monkey: %s\n", err)
+		return nil, "", false
+	}
+
+	if mkc.IsCompiled(data) {
+		fmt.Fprintf(os.Stderr, "monkey: %s: already compiled\n", path)
+		return nil, "", false
+	}
+
+	p := parser.NewParser(string(data))
+	prog := p.Parse()
+
+	if len(p.Errors()) != 0 {
+		printer := diagnostic.NewPrinter(path, string(data), colorize(os.Stderr))
+		printer.PrintAll(os.Stderr, p.Errors())
+		return nil, "", false
+	}
+
+	c := compiler.New()
+	if err := c.Compile(optimizer.Optimize(prog, level)); err != nil {
+		fmt.Fprintf(os.Stderr, "monkey: %s:%s\n", path, err)
+		return nil, "", false
+	}
+
+	bytecode := c.Bytecode()
+	if level >= optimizer.Full {
+		peephole.Optimize(bytecode)
+	}
+	return bytecode, string(data), true
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"os"
+
+	"github.com/hellozee/monkey/lib/disasm"
+	"github.com/hellozee/monkey/lib/optimizer"
+)
+
+// disassemble compiles the script at path and prints its bytecode.
+func disassemble(path string, level optimizer.Level) int {
+	bytecode, source, ok := compilescript(path, level)
+	if !ok {
+		return 1
+	}
+
+	disasm.NewPrinter(path, source).Print(os.Stdout, bytecode)
+	return 0
+}
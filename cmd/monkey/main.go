@@ -1,5 +1,81 @@
 package main
 
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hellozee/monkey/lib/optimizer"
+	"github.com/hellozee/monkey/lib/repl"
+)
+
+const usage = `usage:
+  monkey                                  start the interactive REPL
+  monkey run [-On] <script> [args...]     evaluate a script or a compiled .mkc file
+  monkey build [-On] <script> [output]    compile a script to a .mkc file
+  monkey disasm [-On] <script>            print the bytecode a script compiles to
+
+  -On sets the optimization level: -O0 turns optimizations off, -O1 folds
+  constant expressions and -O2, the default, also removes dead branches
+  and optimizes the bytecode.
+`
+
 func main() {
+	if len(os.Args) < 2 {
+		fmt.Println("Monkey REPL, type :help for a list of commands")
+		repl.Start(os.Stdin, os.Stdout)
+		return
+	}
+
+	command := os.Args[1]
+	if command == "help" || command == "-h" || command == "--help" {
+		fmt.Print(usage)
+		return
+	}
+
+	level, args, err := optlevel(os.Args[2:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "monkey: %s\n", err)
+		os.Exit(2)
+	}
+
+	switch command {
+	case "run":
+		if len(args) < 1 {
+			fmt.Fprint(os.Stderr, usage)
+			os.Exit(2)
+		}
+		os.Exit(run(args[0], args[1:], level))
+	case "build":
+		if len(args) != 1 && len(args) != 2 {
+			fmt.Fprint(os.Stderr, usage)
+			os.Exit(2)
+		}
+		output := ""
+		if len(args) == 2 {
+			output = args[1]
+		}
+		os.Exit(build(args[0], output, level))
+	case "disasm":
+		if len(args) != 1 {
+			fmt.Fprint(os.Stderr, usage)
+			os.Exit(2)
+		}
+		os.Exit(disassemble(args[0], level))
+	default:
+		fmt.Fprintf(os.Stderr, "monkey: unknown command %q\n", command)
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+// optlevel takes a -On flag off the front of args, arguments after the
+// script are the script's own and aren't looked at.
+func optlevel(args []string) (optimizer.Level, []string, error) {
+	if len(args) == 0 || !strings.HasPrefix(args[0], "-O") {
+		return optimizer.Default, args, nil
+	}
 
+	level, err := optimizer.ParseLevel(strings.TrimPrefix(args[0], "-O"))
+	return level, args[1:], err
 }
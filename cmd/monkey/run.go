@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/hellozee/monkey/lib/diagnostic"
+	"github.com/hellozee/monkey/lib/evaluator"
+	"github.com/hellozee/monkey/lib/mkc"
+	"github.com/hellozee/monkey/lib/object"
+	"github.com/hellozee/monkey/lib/optimizer"
+	"github.com/hellozee/monkey/lib/parser"
+	"github.com/hellozee/monkey/lib/vm"
+)
+
+// run evaluates the script at path and returns the process exit status.
+// Integer results become the status (truncated to 0-255 like a shell),
+// parse and runtime errors exit with 1, anything else exits with 0.
+// Compiled programs are run on the VM, they were optimized when built.
+func run(path string, args []string, level optimizer.Level) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "monkey: %s\n", err)
+		return 1
+	}
+
+	if mkc.IsCompiled(data) {
+		return runcompiled(path, data, args)
+	}
+
+	printer := diagnostic.NewPrinter(path, string(data), colorize(os.Stderr))
+	p := parser.NewParser(string(data))
+	prog := p.Parse()
+
+	if len(p.Errors()) != 0 {
+		printer.PrintAll(os.Stderr, p.Errors())
+		return 1
+	}
+
+	env := object.NewEnvironment()
+	env.Set("args", scriptargs(args))
+
+	return exitstatus(printer, evaluator.Eval(optimizer.Optimize(prog, level), env))
+}
+
+func runcompiled(path string, data []byte, args []string) int {
+	file, err := mkc.Read(bytes.NewReader(data))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "monkey: %s: %s\n", path, err)
+		return 1
+	}
+
+	machine := vm.New(file.Bytecode)
+	machine.SetGlobal("args", scriptargs(args))
+
+	printer := diagnostic.NewPrinter(file.Source, file.Text, colorize(os.Stderr))
+	return exitstatus(printer, machine.Run())
+}
+
+func exitstatus(printer *diagnostic.Printer, result object.Object) int {
+	switch result := result.(type) {
+	case *object.Error:
+		printer.Print(os.Stderr, diagnostic.FromRuntimeError(result))
+		return 1
+	case *object.Integer:
+		return int(result.Value & 0xff)
+	case *object.BigInteger:
+		return int(new(big.Int).And(result.Value, big.NewInt(0xff)).Int64())
+	default:
+		return 0
+	}
+}
+
+func scriptargs(args []string) *object.Array {
+	elements := make([]object.Object, len(args))
+	for i, arg := range args {
+		elements[i] = &object.String{Value: arg}
+	}
+	return &object.Array{Elements: elements}
+}
+
+// colorize reports whether diagnostics written to f should use colors,
+// which is only the case for terminals and when NO_COLOR is not set.
+func colorize(f *os.File) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,238 @@
+package code
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hellozee/monkey/lib/parser"
+)
+
+type Instructions []byte
+
+// String lists one instruction per line with its offset and operands.
+func (ins Instructions) String() string {
+	var out bytes.Buffer
+
+	for i := 0; i < len(ins); {
+		def, err := Lookup(ins[i])
+		if err != nil {
+			fmt.Fprintf(&out, "%04d ERROR: %s\n", i, err)
+			i++
+			continue
+		}
+
+		operands, read := ReadOperands(def, ins[i+1:])
+		fmt.Fprintf(&out, "%04d %s\n", i, FormatInstruction(def, operands))
+		i += 1 + read
+	}
+
+	return out.String()
+}
+
+// FormatInstruction writes an instruction as its name followed by its
+// operands.
+func FormatInstruction(def *Definition, operands []int) string {
+	parts := []string{def.Name}
+	for _, o := range operands {
+		parts = append(parts, fmt.Sprint(o))
+	}
+	return strings.Join(parts, " ")
+}
+
+type Opcode byte
+
+const (
+	OpConstant Opcode = iota
+	OpPop
+
+	OpAdd
+	OpSub
+	OpMul
+	OpDiv
+	OpEqual
+	OpNotEqual
+	OpGreaterThan
+	OpLessThan
+	OpMinus
+	OpBang
+
+	OpTrue
+	OpFalse
+	OpNull
+
+	OpJump
+	OpJumpNotTruthy
+
+	OpGetGlobal
+	OpSetGlobal
+	OpGetLocal
+	OpSetLocal
+	OpGetOuter
+
+	OpArray
+	OpHash
+	OpHashKey
+	OpIndex
+	OpSlice
+
+	OpClosure
+	OpCall
+	OpReturnValue
+	OpReturn
+
+	// Superinstructions, only emitted by the peephole optimizer.
+	OpInfixLocalConstant
+)
+
+// Operands of OpSlice, telling which bounds are on the stack.
+const (
+	SliceLow  = 1
+	SliceHigh = 2
+)
+
+type Definition struct {
+	Name          string
+	OperandWidths []int
+}
+
+var definitions = map[Opcode]*Definition{
+	OpConstant: {"OpConstant", []int{2}},
+	OpPop:      {"OpPop", []int{}},
+
+	OpAdd:         {"OpAdd", []int{}},
+	OpSub:         {"OpSub", []int{}},
+	OpMul:         {"OpMul", []int{}},
+	OpDiv:         {"OpDiv", []int{}},
+	OpEqual:       {"OpEqual", []int{}},
+	OpNotEqual:    {"OpNotEqual", []int{}},
+	OpGreaterThan: {"OpGreaterThan", []int{}},
+	OpLessThan:    {"OpLessThan", []int{}},
+	OpMinus:       {"OpMinus", []int{}},
+	OpBang:        {"OpBang", []int{}},
+
+	OpTrue:  {"OpTrue", []int{}},
+	OpFalse: {"OpFalse", []int{}},
+	OpNull:  {"OpNull", []int{}},
+
+	OpJump:          {"OpJump", []int{2}},
+	OpJumpNotTruthy: {"OpJumpNotTruthy", []int{2}},
+
+	OpGetGlobal: {"OpGetGlobal", []int{2}},
+	OpSetGlobal: {"OpSetGlobal", []int{2}},
+	OpGetLocal:  {"OpGetLocal", []int{2}},
+	OpSetLocal:  {"OpSetLocal", []int{2}},
+	OpGetOuter:  {"OpGetOuter", []int{1, 2}},
+
+	OpArray:   {"OpArray", []int{2}},
+	OpHash:    {"OpHash", []int{2}},
+	OpHashKey: {"OpHashKey", []int{}},
+	OpIndex:   {"OpIndex", []int{}},
+	OpSlice:   {"OpSlice", []int{1}},
+
+	OpClosure:     {"OpClosure", []int{2}},
+	OpCall:        {"OpCall", []int{1}},
+	OpReturnValue: {"OpReturnValue", []int{}},
+	OpReturn:      {"OpReturn", []int{}},
+
+	// The operands are a local, a constant and the opcode of the infix
+	// operator applied to them.
+	OpInfixLocalConstant: {"OpInfixLocalConstant", []int{2, 2, 1}},
+}
+
+// InfixOperators maps the opcodes of infix operators to the operators.
+var InfixOperators = map[Opcode]string{
+	OpAdd:         "+",
+	OpSub:         "-",
+	OpMul:         "*",
+	OpDiv:         "/",
+	OpEqual:       "==",
+	OpNotEqual:    "!=",
+	OpGreaterThan: ">",
+	OpLessThan:    "<",
+}
+
+func Lookup(op byte) (*Definition, error) {
+	def, ok := definitions[Opcode(op)]
+	if !ok {
+		return nil, fmt.Errorf("opcode %d undefined", op)
+	}
+	return def, nil
+}
+
+// Make encodes op and its operands, operands are big endian. It returns
+// an empty instruction for unknown opcodes.
+func Make(op Opcode, operands ...int) []byte {
+	def, ok := definitions[op]
+	if !ok {
+		return []byte{}
+	}
+
+	length := 1
+	for _, w := range def.OperandWidths {
+		length += w
+	}
+
+	instruction := make([]byte, length)
+	instruction[0] = byte(op)
+
+	offset := 1
+	for i, o := range operands {
+		switch def.OperandWidths[i] {
+		case 1:
+			instruction[offset] = byte(o)
+		case 2:
+			binary.BigEndian.PutUint16(instruction[offset:], uint16(o))
+		}
+		offset += def.OperandWidths[i]
+	}
+
+	return instruction
+}
+
+// ReadOperands decodes the operands following an opcode described by def
+// and returns them with the number of bytes read.
+func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
+	operands := make([]int, len(def.OperandWidths))
+	offset := 0
+
+	for i, width := range def.OperandWidths {
+		switch width {
+		case 1:
+			operands[i] = int(ReadUint8(ins[offset:]))
+		case 2:
+			operands[i] = int(ReadUint16(ins[offset:]))
+		}
+		offset += width
+	}
+
+	return operands, offset
+}
+
+func ReadUint8(ins Instructions) uint8 { return ins[0] }
+
+func ReadUint16(ins Instructions) uint16 {
+	return binary.BigEndian.Uint16(ins)
+}
+
+// Position records that the instructions from Offset up to the next entry
+// were produced by the source between Pos and End.
+type Position struct {
+	Offset int
+	Pos    parser.Position
+	End    parser.Position
+}
+
+// Positions is a table of positions sorted by offset.
+type Positions []Position
+
+// Lookup returns the position of the instruction at offset.
+func (p Positions) Lookup(offset int) (Position, bool) {
+	i := sort.Search(len(p), func(i int) bool { return p[i].Offset > offset })
+	if i == 0 {
+		return Position{}, false
+	}
+	return p[i-1], true
+}
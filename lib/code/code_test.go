@@ -0,0 +1,132 @@
+package code
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/hellozee/monkey/lib/parser"
+)
+
+func TestMake(t *testing.T) {
+	tests := []struct {
+		op       Opcode
+		operands []int
+		expected []byte
+	}{
+		{OpConstant, []int{65534}, []byte{byte(OpConstant), 255, 254}},
+		{OpAdd, []int{}, []byte{byte(OpAdd)}},
+		{OpCall, []int{255}, []byte{byte(OpCall), 255}},
+		{OpGetOuter, []int{2, 258}, []byte{byte(OpGetOuter), 2, 1, 2}},
+	}
+
+	for _, tt := range tests {
+		instruction := Make(tt.op, tt.operands...)
+
+		if !bytes.Equal(instruction, tt.expected) {
+			t.Errorf("Make(%d, %v) wrong. expected=%v, got=%v", tt.op, tt.operands, tt.expected, instruction)
+		}
+	}
+}
+
+func TestInstructionsString(t *testing.T) {
+	instructions := []Instructions{
+		Make(OpAdd),
+		Make(OpGetLocal, 1),
+		Make(OpConstant, 2),
+		Make(OpGetOuter, 1, 65535),
+		Make(OpSlice, SliceLow),
+	}
+
+	expected := `0000 OpAdd
+0001 OpGetLocal 1
+0004 OpConstant 2
+0007 OpGetOuter 1 65535
+0011 OpSlice 1
+`
+
+	concatted := Instructions{}
+	for _, ins := range instructions {
+		concatted = append(concatted, ins...)
+	}
+
+	if concatted.String() != expected {
+		t.Errorf("instructions wrongly formatted.\nwant=%q\ngot=%q", expected, concatted.String())
+	}
+}
+
+func TestReadOperands(t *testing.T) {
+	tests := []struct {
+		op        Opcode
+		operands  []int
+		bytesread int
+	}{
+		{OpConstant, []int{65535}, 2},
+		{OpSlice, []int{SliceLow | SliceHigh}, 1},
+		{OpGetOuter, []int{1, 300}, 3},
+		{OpInfixLocalConstant, []int{2, 65535, int(OpSub)}, 5},
+		{OpPop, []int{}, 0},
+	}
+
+	for _, tt := range tests {
+		instruction := Make(tt.op, tt.operands...)
+
+		def, err := Lookup(byte(tt.op))
+		if err != nil {
+			t.Fatalf("definition not found: %q", err)
+		}
+
+		operands, n := ReadOperands(def, instruction[1:])
+		if n != tt.bytesread {
+			t.Fatalf("n wrong. expected=%d, got=%d", tt.bytesread, n)
+		}
+
+		for i, want := range tt.operands {
+			if operands[i] != want {
+				t.Errorf("operand %d wrong. expected=%d, got=%d", i, want, operands[i])
+			}
+		}
+	}
+}
+
+func TestEveryOpcodeIsDefined(t *testing.T) {
+	for op := OpConstant; op <= OpInfixLocalConstant; op++ {
+		if _, err := Lookup(byte(op)); err != nil {
+			t.Errorf("opcode %d has no definition", op)
+		}
+	}
+
+	if _, err := Lookup(byte(OpInfixLocalConstant) + 1); err == nil {
+		t.Errorf("expected an error for an unknown opcode")
+	}
+}
+
+func TestPositionsLookup(t *testing.T) {
+	positions := Positions{
+		{Offset: 0, Pos: parser.Position{Line: 1, Column: 1}},
+		{Offset: 3, Pos: parser.Position{Line: 2, Column: 1}},
+		{Offset: 7, Pos: parser.Position{Line: 4, Column: 2}},
+	}
+
+	tests := []struct {
+		offset int
+		line   int
+	}{
+		{0, 1},
+		{2, 1},
+		{3, 2},
+		{6, 2},
+		{7, 4},
+		{100, 4},
+	}
+
+	for _, tt := range tests {
+		pos, ok := positions.Lookup(tt.offset)
+		if !ok || pos.Pos.Line != tt.line {
+			t.Errorf("Lookup(%d) wrong. expected line %d, got=%+v", tt.offset, tt.line, pos)
+		}
+	}
+
+	if _, ok := (Positions{}).Lookup(0); ok {
+		t.Errorf("empty table should not find a position")
+	}
+}
@@ -0,0 +1,498 @@
+package compiler
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/hellozee/monkey/lib/code"
+	"github.com/hellozee/monkey/lib/object"
+	"github.com/hellozee/monkey/lib/parser"
+)
+
+// Bytecode is a compiled program, Globals names every global slot.
+type Bytecode struct {
+	Main      *object.CompiledFunction
+	Constants []object.Object
+	Globals   []string
+}
+
+type emittedinstruction struct {
+	opcode   code.Opcode
+	position int
+}
+
+// compilationscope holds the output for the function being compiled.
+type compilationscope struct {
+	instructions code.Instructions
+	positions    code.Positions
+	last         emittedinstruction
+	previous     emittedinstruction
+}
+
+type Compiler struct {
+	constants []object.Object
+	symbols   *SymbolTable
+
+	scopes []compilationscope
+}
+
+func New() *Compiler {
+	return &Compiler{
+		symbols: NewSymbolTable(),
+		scopes:  []compilationscope{{}},
+	}
+}
+
+// Compile lowers node, the program's value is left on the stack when its
+// last statement is an expression.
+func (c *Compiler) Compile(node parser.Node) error {
+	prog, ok := node.(*parser.Program)
+	if !ok {
+		return c.compile(node)
+	}
+
+	for _, stmt := range prog.Statements {
+		if err := c.compile(stmt); err != nil {
+			return err
+		}
+	}
+
+	if endsinexpression(prog.Statements) {
+		c.removelastpop()
+	}
+
+	return c.checklimits(prog)
+}
+
+func (c *Compiler) Bytecode() *Bytecode {
+	return &Bytecode{
+		Main: &object.CompiledFunction{
+			Instructions: c.scope().instructions,
+			Positions:    c.scope().positions,
+		},
+		Constants: c.constants,
+		Globals:   c.symbols.Names(),
+	}
+}
+
+func (c *Compiler) compile(node parser.Node) error {
+	switch node := node.(type) {
+	case *parser.ExpressionStatement:
+		if err := c.compile(node.Expr); err != nil {
+			return err
+		}
+		c.emit(node, code.OpPop)
+	case *parser.BlockStatement:
+		for _, stmt := range node.Statements {
+			if err := c.compile(stmt); err != nil {
+				return err
+			}
+		}
+	case *parser.LetStatement:
+		if err := c.compile(node.Value); err != nil {
+			return err
+		}
+		sym := c.symbols.Define(node.Name.Value)
+		if sym.Scope == GlobalScope {
+			c.emit(node, code.OpSetGlobal, sym.Index)
+		} else {
+			c.emit(node, code.OpSetLocal, sym.Index)
+		}
+	case *parser.ReturnStatement:
+		if node.Value == nil {
+			c.emit(node, code.OpReturn)
+			break
+		}
+		if err := c.compile(node.Value); err != nil {
+			return err
+		}
+		c.emit(node, code.OpReturnValue)
+	case *parser.BadStatement:
+		return fmt.Errorf("%s: cannot compile a statement that failed to parse", node.Pos())
+	case *parser.Identifier:
+		return c.loadsymbol(node, c.symbols.Resolve(node.Value))
+	case *parser.IntLiteral:
+		if node.Big != nil {
+			c.emit(node, code.OpConstant, c.addconstant(&object.BigInteger{Value: node.Big}))
+			break
+		}
+		c.emit(node, code.OpConstant, c.addconstant(&object.Integer{Value: node.Value}))
+	case *parser.FloatLiteral:
+		c.emit(node, code.OpConstant, c.addconstant(&object.Float{Value: node.Value}))
+	case *parser.StringLiteral:
+		c.emit(node, code.OpConstant, c.addconstant(&object.String{Value: node.Value}))
+	case *parser.BoolExpr:
+		if node.Value {
+			c.emit(node, code.OpTrue)
+		} else {
+			c.emit(node, code.OpFalse)
+		}
+	case *parser.PrefixExpr:
+		if err := c.compile(node.Right); err != nil {
+			return err
+		}
+		switch node.Operator {
+		case "!":
+			c.emit(node, code.OpBang)
+		case "-":
+			c.emit(node, code.OpMinus)
+		default:
+			return fmt.Errorf("%s: unknown operator %s", node.Pos(), node.Operator)
+		}
+	case *parser.InfixExpr:
+		if err := c.compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.compile(node.Right); err != nil {
+			return err
+		}
+		op, ok := infixops[node.Operator]
+		if !ok {
+			return fmt.Errorf("%s: unknown operator %s", node.Pos(), node.Operator)
+		}
+		c.emit(node, op)
+	case *parser.IfExpr:
+		return c.compileifexpr(node)
+	case *parser.FnLiteral:
+		return c.compilefnliteral(node)
+	case *parser.CallExpr:
+		if err := c.compile(node.Function); err != nil {
+			return err
+		}
+		for _, arg := range node.Arguments {
+			if err := c.compile(arg); err != nil {
+				return err
+			}
+		}
+		if len(node.Arguments) > math.MaxUint8 {
+			return fmt.Errorf("%s: too many arguments in call", node.Pos())
+		}
+		c.emit(node, code.OpCall, len(node.Arguments))
+	case *parser.ArrayLiteral:
+		for _, e := range node.Elements {
+			if err := c.compile(e); err != nil {
+				return err
+			}
+		}
+		if len(node.Elements) > math.MaxUint16 {
+			return fmt.Errorf("%s: too many elements in array literal", node.Pos())
+		}
+		c.emit(node, code.OpArray, len(node.Elements))
+	case *parser.HashLiteral:
+		for _, pair := range node.Pairs {
+			if err := c.compile(pair.Key); err != nil {
+				return err
+			}
+			c.emit(pair.Key, code.OpHashKey)
+			if err := c.compile(pair.Value); err != nil {
+				return err
+			}
+		}
+		if len(node.Pairs) > math.MaxUint16 {
+			return fmt.Errorf("%s: too many pairs in hash literal", node.Pos())
+		}
+		c.emit(node, code.OpHash, len(node.Pairs))
+	case *parser.IndexExpr:
+		if err := c.compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.compile(node.Index); err != nil {
+			return err
+		}
+		c.emit(node, code.OpIndex)
+	case *parser.SliceExpr:
+		return c.compilesliceexpr(node)
+	default:
+		return fmt.Errorf("%s: cannot compile %T", node.Pos(), node)
+	}
+
+	return nil
+}
+
+var infixops = map[string]code.Opcode{
+	"+":  code.OpAdd,
+	"-":  code.OpSub,
+	"*":  code.OpMul,
+	"/":  code.OpDiv,
+	"==": code.OpEqual,
+	"!=": code.OpNotEqual,
+	">":  code.OpGreaterThan,
+	"<":  code.OpLessThan,
+}
+
+func (c *Compiler) compileifexpr(node *parser.IfExpr) error {
+	if err := c.compile(node.Condition); err != nil {
+		return err
+	}
+
+	jumpnottruthy := c.emit(node, code.OpJumpNotTruthy, 0)
+
+	if err := c.compileblockvalue(node.Consequence); err != nil {
+		return err
+	}
+
+	jump := c.emit(node, code.OpJump, 0)
+	c.patchjump(jumpnottruthy)
+
+	if node.Alternative == nil {
+		c.emit(node, code.OpNull)
+	} else if err := c.compileblockvalue(node.Alternative); err != nil {
+		return err
+	}
+
+	c.patchjump(jump)
+	return nil
+}
+
+// compileblockvalue compiles a block whose value is left on the stack,
+// blocks not ending in an expression are null.
+func (c *Compiler) compileblockvalue(block *parser.BlockStatement) error {
+	if err := c.compile(block); err != nil {
+		return err
+	}
+
+	if endsinexpression(block.Statements) {
+		c.removelastpop()
+	} else {
+		c.emit(block, code.OpNull)
+	}
+	return nil
+}
+
+func (c *Compiler) compilefnliteral(node *parser.FnLiteral) error {
+	c.enterscope()
+
+	for _, param := range node.Parameters {
+		c.symbols.define(param.Value)
+	}
+
+	// lets anywhere in the body get their slot up front so closures
+	// created before a let still see the variable once it is set
+	for _, name := range letnames(node.Body, nil) {
+		c.symbols.Define(name)
+	}
+
+	if err := c.compile(node.Body); err != nil {
+		c.leavescope()
+		return err
+	}
+
+	if endsinexpression(node.Body.Statements) {
+		c.replacelastpopwithreturn()
+	}
+	if !c.lastinstructionis(code.OpReturnValue) && !c.lastinstructionis(code.OpReturn) {
+		c.emit(node.Body, code.OpReturn)
+	}
+
+	if err := c.checklimits(node); err != nil {
+		c.leavescope()
+		return err
+	}
+
+	locals := c.symbols.Names()
+	scope := c.leavescope()
+
+	fn := &object.CompiledFunction{
+		Instructions:  scope.instructions,
+		Positions:     scope.positions,
+		NumParameters: len(node.Parameters),
+		Locals:        locals,
+		Source:        node.Source(),
+	}
+
+	c.emit(node, code.OpClosure, c.addconstant(fn))
+	return nil
+}
+
+func (c *Compiler) compilesliceexpr(node *parser.SliceExpr) error {
+	if err := c.compile(node.Left); err != nil {
+		return err
+	}
+
+	flags := 0
+
+	if node.Low != nil {
+		if err := c.compile(node.Low); err != nil {
+			return err
+		}
+		flags |= code.SliceLow
+	}
+
+	if node.High != nil {
+		if err := c.compile(node.High); err != nil {
+			return err
+		}
+		flags |= code.SliceHigh
+	}
+
+	c.emit(node, code.OpSlice, flags)
+	return nil
+}
+
+func (c *Compiler) loadsymbol(node parser.Node, sym Symbol) error {
+	switch sym.Scope {
+	case GlobalScope:
+		c.emit(node, code.OpGetGlobal, sym.Index)
+	case LocalScope:
+		c.emit(node, code.OpGetLocal, sym.Index)
+	case OuterScope:
+		if sym.Depth > math.MaxUint8 {
+			return fmt.Errorf("%s: %s is defined too many functions out", node.Pos(), sym.Name)
+		}
+		c.emit(node, code.OpGetOuter, sym.Depth, sym.Index)
+	}
+	return nil
+}
+
+// letnames appends the names bound by lets in node to names, without
+// looking into nested functions which have their own scope.
+func letnames(node parser.Node, names []string) []string {
+	switch node := node.(type) {
+	case *parser.BlockStatement:
+		for _, stmt := range node.Statements {
+			names = letnames(stmt, names)
+		}
+	case *parser.LetStatement:
+		names = append(names, node.Name.Value)
+		names = letnames(node.Value, names)
+	case *parser.ReturnStatement:
+		if node.Value != nil {
+			names = letnames(node.Value, names)
+		}
+	case *parser.ExpressionStatement:
+		names = letnames(node.Expr, names)
+	case *parser.PrefixExpr:
+		names = letnames(node.Right, names)
+	case *parser.InfixExpr:
+		names = letnames(node.Left, names)
+		names = letnames(node.Right, names)
+	case *parser.IfExpr:
+		names = letnames(node.Condition, names)
+		names = letnames(node.Consequence, names)
+		if node.Alternative != nil {
+			names = letnames(node.Alternative, names)
+		}
+	case *parser.CallExpr:
+		names = letnames(node.Function, names)
+		for _, arg := range node.Arguments {
+			names = letnames(arg, names)
+		}
+	case *parser.ArrayLiteral:
+		for _, e := range node.Elements {
+			names = letnames(e, names)
+		}
+	case *parser.HashLiteral:
+		for _, pair := range node.Pairs {
+			names = letnames(pair.Key, names)
+			names = letnames(pair.Value, names)
+		}
+	case *parser.IndexExpr:
+		names = letnames(node.Left, names)
+		names = letnames(node.Index, names)
+	case *parser.SliceExpr:
+		names = letnames(node.Left, names)
+		if node.Low != nil {
+			names = letnames(node.Low, names)
+		}
+		if node.High != nil {
+			names = letnames(node.High, names)
+		}
+	}
+	return names
+}
+
+func endsinexpression(stmts []parser.Statement) bool {
+	if len(stmts) == 0 {
+		return false
+	}
+	_, ok := stmts[len(stmts)-1].(*parser.ExpressionStatement)
+	return ok
+}
+
+// checklimits reports when the current function or the program outgrew
+// what the two byte operands can address.
+func (c *Compiler) checklimits(node parser.Node) error {
+	switch {
+	case len(c.scope().instructions) > math.MaxUint16:
+		return fmt.Errorf("%s: function too large, jumps can't reach past %d bytes", node.Pos(), math.MaxUint16)
+	case len(c.constants) > math.MaxUint16+1:
+		return fmt.Errorf("%s: too many constants", node.Pos())
+	case len(c.symbols.Names()) > math.MaxUint16+1:
+		return fmt.Errorf("%s: too many variables", node.Pos())
+	}
+	return nil
+}
+
+func (c *Compiler) scope() *compilationscope {
+	return &c.scopes[len(c.scopes)-1]
+}
+
+func (c *Compiler) enterscope() {
+	c.scopes = append(c.scopes, compilationscope{})
+	c.symbols = NewEnclosedSymbolTable(c.symbols)
+}
+
+func (c *Compiler) leavescope() compilationscope {
+	scope := *c.scope()
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.symbols = c.symbols.Outer
+	return scope
+}
+
+func (c *Compiler) addconstant(obj object.Object) int {
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1
+}
+
+// emit appends an instruction produced by node and returns its offset.
+func (c *Compiler) emit(node parser.Node, op code.Opcode, operands ...int) int {
+	scope := c.scope()
+	position := len(scope.instructions)
+
+	span := code.Position{Offset: position, Pos: node.Pos(), End: node.End()}
+	if n := len(scope.positions); n == 0 || scope.positions[n-1].Pos != span.Pos || scope.positions[n-1].End != span.End {
+		scope.positions = append(scope.positions, span)
+	}
+
+	scope.instructions = append(scope.instructions, code.Make(op, operands...)...)
+	scope.previous = scope.last
+	scope.last = emittedinstruction{opcode: op, position: position}
+	return position
+}
+
+func (c *Compiler) lastinstructionis(op code.Opcode) bool {
+	if len(c.scope().instructions) == 0 {
+		return false
+	}
+	return c.scope().last.opcode == op
+}
+
+func (c *Compiler) removelastpop() {
+	scope := c.scope()
+	if scope.last.opcode != code.OpPop {
+		return
+	}
+
+	scope.instructions = scope.instructions[:scope.last.position]
+	for n := len(scope.positions); n > 0 && scope.positions[n-1].Offset >= scope.last.position; n-- {
+		scope.positions = scope.positions[:n-1]
+	}
+	scope.last = scope.previous
+}
+
+func (c *Compiler) replacelastpopwithreturn() {
+	scope := c.scope()
+	if scope.last.opcode != code.OpPop {
+		return
+	}
+
+	scope.instructions[scope.last.position] = byte(code.OpReturnValue)
+	scope.last.opcode = code.OpReturnValue
+}
+
+// patchjump points the jump at offset to the next instruction.
+func (c *Compiler) patchjump(offset int) {
+	scope := c.scope()
+	op := code.Opcode(scope.instructions[offset])
+	copy(scope.instructions[offset:], code.Make(op, len(scope.instructions)))
+}
@@ -0,0 +1,419 @@
+package compiler
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/hellozee/monkey/lib/code"
+	"github.com/hellozee/monkey/lib/object"
+	"github.com/hellozee/monkey/lib/parser"
+)
+
+type compilertestcase struct {
+	input        string
+	constants    []interface{}
+	instructions []code.Instructions
+}
+
+func TestIntegerArithmetic(t *testing.T) {
+	tests := []compilertestcase{
+		{
+			input:     "1 + 2",
+			constants: []interface{}{1, 2},
+			instructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+			},
+		},
+		{
+			input:     "1; 2",
+			constants: []interface{}{1, 2},
+			instructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 1),
+			},
+		},
+		{
+			input:     "2 < 1",
+			constants: []interface{}{2, 1},
+			instructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpLessThan),
+			},
+		},
+		{
+			input:     "-1; !true",
+			constants: []interface{}{1},
+			instructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpMinus),
+				code.Make(code.OpPop),
+				code.Make(code.OpTrue),
+				code.Make(code.OpBang),
+			},
+		},
+	}
+
+	runcompilertests(t, tests)
+}
+
+func TestConditionals(t *testing.T) {
+	tests := []compilertestcase{
+		{
+			input:     "if (true) { 10 }; 3333;",
+			constants: []interface{}{10, 3333},
+			instructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 10),
+				// 0004
+				code.Make(code.OpConstant, 0),
+				// 0007
+				code.Make(code.OpJump, 11),
+				// 0010
+				code.Make(code.OpNull),
+				// 0011
+				code.Make(code.OpPop),
+				// 0012
+				code.Make(code.OpConstant, 1),
+			},
+		},
+		{
+			input:     "if (true) { 10 } else { 20 }",
+			constants: []interface{}{10, 20},
+			instructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 10),
+				// 0004
+				code.Make(code.OpConstant, 0),
+				// 0007
+				code.Make(code.OpJump, 13),
+				// 0010
+				code.Make(code.OpConstant, 1),
+			},
+		},
+		{
+			input:     "if (true) { let a = 1 }",
+			constants: []interface{}{1},
+			instructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 14),
+				// 0004
+				code.Make(code.OpConstant, 0),
+				// 0007
+				code.Make(code.OpSetGlobal, 0),
+				// 0010
+				code.Make(code.OpNull),
+				// 0011
+				code.Make(code.OpJump, 15),
+				// 0014
+				code.Make(code.OpNull),
+			},
+		},
+	}
+
+	runcompilertests(t, tests)
+}
+
+func TestGlobalLetStatements(t *testing.T) {
+	tests := []compilertestcase{
+		{
+			input:     "let one = 1; let two = one; two",
+			constants: []interface{}{1},
+			instructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpSetGlobal, 1),
+				code.Make(code.OpGetGlobal, 1),
+			},
+		},
+		{
+			input:     "let one = 1; let one = 2;",
+			constants: []interface{}{1, 2},
+			instructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpSetGlobal, 0),
+			},
+		},
+		{
+			input:     "later; let later = 1;",
+			constants: []interface{}{1},
+			instructions: []code.Instructions{
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+			},
+		},
+	}
+
+	runcompilertests(t, tests)
+}
+
+func TestCollections(t *testing.T) {
+	tests := []compilertestcase{
+		{
+			input:     "[1, 2][0:]",
+			constants: []interface{}{1, 2, 0},
+			instructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpArray, 2),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpSlice, code.SliceLow),
+			},
+		},
+		{
+			input:     `{"a": 1}["a"]`,
+			constants: []interface{}{"a", 1, "a"},
+			instructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpHashKey),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpHash, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpIndex),
+			},
+		},
+	}
+
+	runcompilertests(t, tests)
+}
+
+func TestFunctions(t *testing.T) {
+	tests := []compilertestcase{
+		{
+			input: "fn(a) { let b = a; fn() { a + b } }",
+			constants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGetOuter, 1, 0),
+					code.Make(code.OpGetOuter, 1, 1),
+					code.Make(code.OpAdd),
+					code.Make(code.OpReturnValue),
+				},
+				[]code.Instructions{
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpSetLocal, 1),
+					code.Make(code.OpClosure, 0),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			instructions: []code.Instructions{
+				code.Make(code.OpClosure, 1),
+			},
+		},
+		{
+			input: "fn() { }(); fn() { return; }",
+			constants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpReturn),
+				},
+				[]code.Instructions{
+					code.Make(code.OpReturn),
+				},
+			},
+			instructions: []code.Instructions{
+				code.Make(code.OpClosure, 0),
+				code.Make(code.OpCall, 0),
+				code.Make(code.OpPop),
+				code.Make(code.OpClosure, 1),
+			},
+		},
+		{
+			input: "fn(f) { f(1, 2) }",
+			constants: []interface{}{
+				1,
+				2,
+				[]code.Instructions{
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpConstant, 1),
+					code.Make(code.OpCall, 2),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			instructions: []code.Instructions{
+				code.Make(code.OpClosure, 2),
+			},
+		},
+	}
+
+	runcompilertests(t, tests)
+}
+
+func TestHoistedLocals(t *testing.T) {
+	bytecode := compile(t, "fn(x) { let g = fn() { y }; if (x) { let y = 1 }; g() }")
+
+	fn := bytecode.Constants[len(bytecode.Constants)-1].(*object.CompiledFunction)
+	if strings.Join(fn.Locals, ",") != "x,g,y" || fn.NumParameters != 1 {
+		t.Errorf("wrong locals. got=%v params=%d", fn.Locals, fn.NumParameters)
+	}
+
+	inner := bytecode.Constants[0].(*object.CompiledFunction)
+	expected := concat([]code.Instructions{code.Make(code.OpGetOuter, 1, 2), code.Make(code.OpReturnValue)})
+	if !bytes.Equal(inner.Instructions, expected) {
+		t.Errorf("inner function doesn't read the hoisted y. got=\n%s", inner.Instructions)
+	}
+}
+
+func TestPositions(t *testing.T) {
+	bytecode := compile(t, "let a = 1;\na + true")
+
+	tests := []struct {
+		offset int
+		pos    string
+		end    string
+	}{
+		{0, "1:9", "1:10"},
+		{3, "1:1", "1:11"},
+		{6, "2:1", "2:2"},
+		{9, "2:5", "2:9"},
+		{10, "2:1", "2:9"},
+	}
+
+	for _, tt := range tests {
+		pos, ok := bytecode.Main.Positions.Lookup(tt.offset)
+		if !ok {
+			t.Fatalf("no position for offset %d", tt.offset)
+		}
+
+		if pos.Pos.String() != tt.pos || pos.End.String() != tt.end {
+			t.Errorf("offset %d wrong. expected=%s-%s, got=%s-%s", tt.offset, tt.pos, tt.end, pos.Pos, pos.End)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	p := parser.NewParser("let = 1;")
+	err := New().Compile(p.Parse())
+
+	if err == nil || err.Error() != "1:1: cannot compile a statement that failed to parse" {
+		t.Errorf("wrong error. got=%v", err)
+	}
+}
+
+// TestOperandLimits checks that counts which don't fit their operand are
+// errors rather than truncated. Literals this large are over the size
+// limit of a function too, which is reported after them.
+func TestOperandLimits(t *testing.T) {
+	nested := func(depth int) string {
+		return "fn(x) { " + strings.Repeat("fn() { ", depth) + "x" + strings.Repeat(" }", depth+1)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[" + strings.Repeat("true, ", math.MaxUint16-1) + "true]", "1:1: function too large, jumps can't reach past 65535 bytes"},
+		{"[" + strings.Repeat("true, ", math.MaxUint16) + "true]", "1:1: too many elements in array literal"},
+		{"{" + strings.Repeat("true: true, ", math.MaxUint16-1) + "true: true}", "1:1: function too large, jumps can't reach past 65535 bytes"},
+		{"{" + strings.Repeat("true: true, ", math.MaxUint16) + "true: true}", "1:1: too many pairs in hash literal"},
+		{nested(math.MaxUint8), ""},
+		{nested(math.MaxUint8 + 1), "1:1801: x is defined too many functions out"},
+	}
+
+	for _, tt := range tests {
+		p := parser.NewParser(tt.input)
+		prog := p.Parse()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("parser errors: %s", p.Errors()[0])
+		}
+
+		err := New().Compile(prog)
+		if tt.expected == "" {
+			if err != nil {
+				t.Errorf("%.20q - unexpected error %s", tt.input, err)
+			}
+			continue
+		}
+
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("%.20q - wrong error. expected=%q, got=%v", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func compile(t *testing.T, input string) *Bytecode {
+	t.Helper()
+
+	p := parser.NewParser(input)
+	prog := p.Parse()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %s", p.Errors())
+	}
+
+	c := New()
+	if err := c.Compile(prog); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	return c.Bytecode()
+}
+
+func runcompilertests(t *testing.T, tests []compilertestcase) {
+	t.Helper()
+
+	for _, tt := range tests {
+		bytecode := compile(t, tt.input)
+
+		expected := concat(tt.instructions)
+		if !bytes.Equal(bytecode.Main.Instructions, expected) {
+			t.Errorf("%q - wrong instructions.\nwant=\n%s\ngot=\n%s", tt.input, expected, bytecode.Main.Instructions)
+		}
+
+		testconstants(t, tt.input, tt.constants, bytecode.Constants)
+	}
+}
+
+func testconstants(t *testing.T, input string, expected []interface{}, actual []object.Object) {
+	t.Helper()
+
+	if len(expected) != len(actual) {
+		t.Errorf("%q - wrong number of constants. expected=%d, got=%d", input, len(expected), len(actual))
+		return
+	}
+
+	for i, constant := range expected {
+		switch constant := constant.(type) {
+		case int:
+			integer, ok := actual[i].(*object.Integer)
+			if !ok || integer.Value != int64(constant) {
+				t.Errorf("%q - constant %d wrong. expected=%d, got=%s", input, i, constant, actual[i].Inspect())
+			}
+		case string:
+			str, ok := actual[i].(*object.String)
+			if !ok || str.Value != constant {
+				t.Errorf("%q - constant %d wrong. expected=%q, got=%s", input, i, constant, actual[i].Inspect())
+			}
+		case []code.Instructions:
+			fn, ok := actual[i].(*object.CompiledFunction)
+			if !ok {
+				t.Errorf("%q - constant %d is not a function. got=%T", input, i, actual[i])
+				continue
+			}
+			if !bytes.Equal(fn.Instructions, concat(constant)) {
+				t.Errorf("%q - constant %d has wrong instructions.\nwant=\n%s\ngot=\n%s", input, i, concat(constant), fn.Instructions)
+			}
+		}
+	}
+}
+
+func concat(s []code.Instructions) code.Instructions {
+	out := code.Instructions{}
+	for _, ins := range s {
+		out = append(out, ins...)
+	}
+	return out
+}
@@ -0,0 +1,86 @@
+package compiler
+
+type SymbolScope string
+
+const (
+	GlobalScope SymbolScope = "GLOBAL"
+	LocalScope  SymbolScope = "LOCAL"
+	OuterScope  SymbolScope = "OUTER"
+)
+
+// Symbol is a resolved name. Outer symbols are locals of an enclosing
+// function, Depth is how many functions out they live.
+type Symbol struct {
+	Name  string
+	Scope SymbolScope
+	Index int
+	Depth int
+}
+
+// SymbolTable maps the names of one function, or the globals for the
+// outermost table, to slots.
+type SymbolTable struct {
+	Outer *SymbolTable
+
+	store map[string]Symbol
+	names []string
+}
+
+func NewSymbolTable() *SymbolTable {
+	return &SymbolTable{store: make(map[string]Symbol)}
+}
+
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewSymbolTable()
+	s.Outer = outer
+	return s
+}
+
+// Define returns the slot for name in this table, a name defined twice
+// keeps its slot like a second let overwrites the first.
+func (s *SymbolTable) Define(name string) Symbol {
+	if sym, ok := s.store[name]; ok {
+		return sym
+	}
+	return s.define(name)
+}
+
+// define always takes a new slot, parameters use it directly so every
+// argument has a place even when names repeat.
+func (s *SymbolTable) define(name string) Symbol {
+	sym := Symbol{Name: name, Scope: LocalScope, Index: len(s.names)}
+	if s.Outer == nil {
+		sym.Scope = GlobalScope
+	}
+
+	s.store[name] = sym
+	s.names = append(s.names, name)
+	return sym
+}
+
+// Resolve finds the innermost definition of name. Names not defined
+// anywhere become globals, they may still be defined before the code
+// referring to them runs and are looked up among the builtins otherwise.
+func (s *SymbolTable) Resolve(name string) Symbol {
+	depth := 0
+
+	for table := s; ; table = table.Outer {
+		if sym, ok := table.store[name]; ok {
+			if sym.Scope == LocalScope && depth > 0 {
+				sym.Scope = OuterScope
+				sym.Depth = depth
+			}
+			return sym
+		}
+
+		if table.Outer == nil {
+			return table.define(name)
+		}
+		depth++
+	}
+}
+
+// Names returns the name of every slot in order.
+func (s *SymbolTable) Names() []string {
+	return s.names
+}
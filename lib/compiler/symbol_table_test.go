@@ -0,0 +1,59 @@
+package compiler
+
+import "testing"
+
+func TestDefine(t *testing.T) {
+	global := NewSymbolTable()
+
+	a := global.Define("a")
+	if a != (Symbol{Name: "a", Scope: GlobalScope, Index: 0}) {
+		t.Errorf("a wrong. got=%+v", a)
+	}
+
+	if again := global.Define("a"); again != a {
+		t.Errorf("redefining a took a new slot. got=%+v", again)
+	}
+
+	local := NewEnclosedSymbolTable(global)
+	local.define("x")
+	local.define("x")
+
+	if x := local.Define("x"); x != (Symbol{Name: "x", Scope: LocalScope, Index: 1}) {
+		t.Errorf("x should resolve to the last parameter. got=%+v", x)
+	}
+
+	if names := local.Names(); len(names) != 2 {
+		t.Errorf("wrong names. got=%v", names)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+
+	first := NewEnclosedSymbolTable(global)
+	first.Define("b")
+
+	second := NewEnclosedSymbolTable(first)
+	second.Define("c")
+
+	tests := []struct {
+		name     string
+		expected Symbol
+	}{
+		{"a", Symbol{Name: "a", Scope: GlobalScope, Index: 0}},
+		{"b", Symbol{Name: "b", Scope: OuterScope, Index: 0, Depth: 1}},
+		{"c", Symbol{Name: "c", Scope: LocalScope, Index: 0}},
+		{"d", Symbol{Name: "d", Scope: GlobalScope, Index: 1}},
+	}
+
+	for _, tt := range tests {
+		if sym := second.Resolve(tt.name); sym != tt.expected {
+			t.Errorf("%s wrong. expected=%+v, got=%+v", tt.name, tt.expected, sym)
+		}
+	}
+
+	if sym := first.Resolve("d"); sym.Index != 1 {
+		t.Errorf("unknown names should keep their global slot. got=%+v", sym)
+	}
+}
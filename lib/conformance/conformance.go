@@ -0,0 +1,225 @@
+// Package conformance holds the programs every way of running Monkey has
+// to agree on, the evaluator and the vm run the same cases in their
+// tests.
+package conformance
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/hellozee/monkey/lib/object"
+	"github.com/hellozee/monkey/lib/parser"
+)
+
+// Case is a program and the Inspect of its value. Errors are written as
+// "ERROR: message @ start-end", a program without a value as "nil".
+// Output is what the program prints with puts.
+type Case struct {
+	Input    string
+	Expected string
+	Output   string
+}
+
+// Backend runs a parsed program with the given builtins.
+type Backend func(prog *parser.Program, builtins *object.Builtins) object.Object
+
+// Run runs every case with backend and reports the differences on t.
+func Run(t *testing.T, backend Backend) {
+	t.Helper()
+
+	for _, tt := range Cases {
+		p := parser.NewParser(tt.Input)
+		prog := p.Parse()
+		if len(p.Errors()) != 0 {
+			t.Errorf("%q has parse errors: %s", tt.Input, p.Errors())
+			continue
+		}
+
+		var out bytes.Buffer
+		got := Describe(backend(prog, object.DefaultBuiltins(&out)))
+
+		if got != tt.Expected {
+			t.Errorf("%q wrong. expected=%q, got=%q", tt.Input, tt.Expected, got)
+		}
+
+		if out.String() != tt.Output {
+			t.Errorf("%q printed wrong output. expected=%q, got=%q", tt.Input, tt.Output, out.String())
+		}
+	}
+}
+
+// Describe formats a program's value the way Case.Expected does.
+func Describe(obj object.Object) string {
+	switch obj := obj.(type) {
+	case nil:
+		return "nil"
+	case *object.Error:
+		return "ERROR: " + obj.Message + " @ " + obj.Pos.String() + "-" + obj.End.String()
+	default:
+		return obj.Inspect()
+	}
+}
+
+var Cases = []Case{
+	// literals and arithmetic
+	{Input: "5", Expected: "5"},
+	{Input: "-10", Expected: "-10"},
+	{Input: "5 + 5 + 5 + 5 - 10", Expected: "10"},
+	{Input: "2 * (5 + 10) / 3", Expected: "10"},
+	{Input: "-50 + 100 + -50", Expected: "0"},
+	{Input: "7 / 2", Expected: "3"},
+	{Input: "7.0 / 2", Expected: "3.5"},
+	{Input: "1 + 2.5", Expected: "3.5"},
+	{Input: "-1.5e3", Expected: "-1500.0"},
+	{Input: "0x10 + 0b11 + 0o7", Expected: "26"},
+	{Input: "9223372036854775807 + 1", Expected: "9223372036854775808"},
+	{Input: "99999999999999999999 * 99999999999999999999", Expected: "9999999999999999999800000000000000000001"},
+	{Input: "(9223372036854775807 + 1) - 1", Expected: "9223372036854775807"},
+	{Input: "-(-9223372036854775807 - 1)", Expected: "9223372036854775808"},
+
+	// booleans and comparison
+	{Input: "true", Expected: "true"},
+	{Input: "1 < 2 == true", Expected: "true"},
+	{Input: "1 > 2", Expected: "false"},
+	{Input: "1 == 1.0", Expected: "true"},
+	{Input: "100000000000000000000 > 1", Expected: "true"},
+	{Input: "!true", Expected: "false"},
+	{Input: "!!5", Expected: "true"},
+	{Input: "!0", Expected: "false"},
+	{Input: `"a" == "a"`, Expected: "true"},
+	{Input: `"a" != "b"`, Expected: "true"},
+	{Input: "fn() {} == fn() {}", Expected: "false"},
+	{Input: "let f = fn() {}; f == f", Expected: "true"},
+	{Input: "[1] == [1]", Expected: "false"},
+
+	// strings
+	{Input: `"hello" + " " + "world"`, Expected: "hello world"},
+	{Input: `"tab\there"`, Expected: "tab\there"},
+
+	// conditionals
+	{Input: "if (true) { 10 }", Expected: "10"},
+	{Input: "if (false) { 10 }", Expected: "null"},
+	{Input: "if (1 > 2) { 10 } else { 20 }", Expected: "20"},
+	{Input: "if (1) { 10 } else if (2) { 20 } else { 30 }", Expected: "10"},
+	{Input: "if (false) { 1 } else if (false) { 2 }", Expected: "null"},
+	{Input: "if (if (false) { 10 }) { 10 } else { 20 }", Expected: "20"},
+	{Input: "if (true) {}", Expected: "null"},
+	{Input: "if (true) { let x = 1 }", Expected: "null"},
+	{Input: "if (true) { 1; 2 }", Expected: "2"},
+	{Input: "if (1 > 2) { 1 / 0 } else { (2 * 3) + -(-4) }", Expected: "10"},
+	{Input: "if (!true) { 1 }", Expected: "null"},
+
+	// let, globals and program values
+	{Input: "", Expected: "nil"},
+	{Input: "// nothing to run", Expected: "nil"},
+	{Input: "let a = 5", Expected: "nil"},
+	{Input: "let a = 5; a", Expected: "5"},
+	{Input: "let a = 5; let b = a * a; b + a", Expected: "30"},
+	{Input: "let a = 1; let a = a + 1; a", Expected: "2"},
+	{Input: "if (true) { let a = 2 }; a", Expected: "2"},
+	{Input: "return 10; 9", Expected: "10"},
+	{Input: "if (true) { if (true) { return 10; } return 1; }", Expected: "10"},
+	{Input: "return;", Expected: "null"},
+
+	// functions and closures
+	{Input: "let f = fn() { 5 + 10 }; f()", Expected: "15"},
+	{Input: "let f = fn(a, b) { a + b }; f(1, 2)", Expected: "3"},
+	{Input: "let f = fn() { return 1; 2 }; f()", Expected: "1"},
+	{Input: "let f = fn() { }; f()", Expected: "null"},
+	{Input: "let f = fn() { let x = 1 }; f()", Expected: "null"},
+	{Input: "let f = fn() { return; }; f()", Expected: "null"},
+	{Input: "let f = fn(x) { if (x) { return 1; } 2 }; f(true) + f(false)", Expected: "3"},
+	{Input: "let f = fn() { let x = if (true) { return 1 }; 2 }; f()", Expected: "1"},
+	{Input: "-if (true) { return 1 }", Expected: "1"},
+	{Input: "fn(x) { if (x) { return 1 } + 1 }(true)", Expected: "1"},
+	{Input: "[if (true) { return 1 }, 5]", Expected: "1"},
+	{Input: "{if (true) { return 1 }: 5}", Expected: "1"},
+	{Input: "[5][0:if (true) { return 1 }]", Expected: "1"},
+	{Input: "len(if (true) { return 1 })", Expected: "1"},
+	{Input: "let f = fn() { [if (true) { return 1 }] }; f() + 1", Expected: "2"},
+	{Input: `let f = fn() { puts(if (true) { return 1 }); 2 }; f()`, Expected: "1"},
+	{Input: "fn(x) { x * 2 }(21)", Expected: "42"},
+	{Input: "let count = fn(n) { if (n == 0) { 0 } else { 1 + count(n - 1) } }; count(3000)", Expected: "3000"},
+	{Input: "len([" + strings.Repeat("1, ", 3000) + "1])", Expected: "3001"},
+	{Input: "fn(a, a) { a }(1, 2)", Expected: "2"},
+	{Input: "let x = 1; let f = fn(x) { x }; f(2) + x", Expected: "3"},
+	{Input: "let f = fn(x) { let x = x * 2; x }; f(3)", Expected: "6"},
+	{Input: "let adder = fn(x) { fn(y) { x + y } }; adder(2)(3)", Expected: "5"},
+	{Input: "let a = fn(x) { fn(y) { fn(z) { x + y + z } } }; a(1)(2)(3)", Expected: "6"},
+	{Input: "let f = fn(x) { let g = fn() { x }; g }; let h = f(7); h()", Expected: "7"},
+	{Input: "let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(15)", Expected: "610"},
+	{Input: "let f = fn() { let g = fn(n) { if (n == 0) { 0 } else { g(n - 1) } }; g(5) }; f()", Expected: "0"},
+	{Input: "let f = fn() { let g = fn() { z }; let z = 1; g() }; f()", Expected: "1"},
+	{Input: "let x = 1; let f = fn() { x }; let x = 2; f()", Expected: "2"},
+	{Input: "let x = 1; let f = fn() { let y = x; let x = 2; y }; f()", Expected: "1"},
+	{Input: "let f = fn() { g() }; let g = fn() { 3 }; f()", Expected: "3"},
+	{Input: "let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } }; let odd = fn(n) { if (n == 0) { false } else { even(n - 1) } }; even(10)", Expected: "true"},
+	{Input: "let twice = fn(f, x) { f(f(x)) }; twice(fn(x) { x * 3 }, 2)", Expected: "18"},
+	{Input: "fn(x) { x }", Expected: "fn(x) { x }"},
+	{Input: "fn() { 1 + 2 + 3 }", Expected: "fn() { ((1 + 2) + 3) }"},
+	{Input: "let f = fn(x) { fn() { if (true) { x * (60 * 60) } } }; f(1)", Expected: "fn() { if (true) { (x * (60 * 60)) } }"},
+
+	// arrays, hashes, index and slice
+	{Input: "[1, 2 * 2, 3 + 3]", Expected: "[1, 4, 6]"},
+	{Input: "[]", Expected: "[]"},
+	{Input: "[1, 2, 3][1]", Expected: "2"},
+	{Input: "[1, 2, 3][-1]", Expected: "3"},
+	{Input: "let a = [1, 2, 3, 4]; a[1:3]", Expected: "[2, 3]"},
+	{Input: "[1, 2, 3][:2]", Expected: "[1, 2]"},
+	{Input: "[1, 2, 3][-2:]", Expected: "[2, 3]"},
+	{Input: "[1, 2, 3][:]", Expected: "[1, 2, 3]"},
+	{Input: "[[1, 2], [3]][0][1]", Expected: "2"},
+	{Input: `{"a": 1, "b": 2}`, Expected: "{a: 1, b: 2}"},
+	{Input: `{"a": 1, "a": 2}`, Expected: "{a: 2}"},
+	{Input: `{}`, Expected: "{}"},
+	{Input: `{"a": 1}["a"]`, Expected: "1"},
+	{Input: `{"a": 1}["b"]`, Expected: "null"},
+	{Input: `{1: "one", true: "yes"}[1 == 1]`, Expected: "yes"},
+	{Input: `let k = "x"; {k: fn() { 5 }}["x"]()`, Expected: "5"},
+
+	// builtins
+	{Input: `len("héllo")`, Expected: "5"},
+	{Input: "len([1, 2, 3])", Expected: "3"},
+	{Input: "first([4, 5])", Expected: "4"},
+	{Input: "first([])", Expected: "null"},
+	{Input: "rest([1, 2, 3])", Expected: "[2, 3]"},
+	{Input: "push([1], 2)", Expected: "[1, 2]"},
+	{Input: "type(1.5)", Expected: "FLOAT"},
+	{Input: "type(fn() {})", Expected: "FUNCTION"},
+	{Input: "type(len)", Expected: "BUILTIN"},
+	{Input: "len", Expected: "builtin function len"},
+	{Input: "let len = fn(x) { 0 }; len([1])", Expected: "0"},
+	{Input: "let f = fn() { len([1]) }; let len = fn(x) { 0 }; f()", Expected: "0"},
+	{Input: "let map = fn(xs, f) { if (len(xs) == 0) { [] } else { push(map(rest(xs), f), f(first(xs))) } }; map([1, 2, 3], fn(x) { x * 2 })", Expected: "[6, 4, 2]"},
+	{Input: `puts("a", 1); puts([1, 2])`, Expected: "null", Output: "a\n1\n[1, 2]\n"},
+	{Input: `let f = fn(x) { puts(x); x }; f(1) + f(2)`, Expected: "3", Output: "1\n2\n"},
+	{Input: `let f = fn(x) { puts(x); x }; [f(1), f(2)][f(0)]`, Expected: "1", Output: "1\n2\n0\n"},
+
+	// runtime errors
+	{Input: "5 + true", Expected: "ERROR: type mismatch: INTEGER + BOOLEAN @ 1:1-1:9"},
+	{Input: "5;\n-true", Expected: "ERROR: unknown operator: -BOOLEAN @ 2:1-2:6"},
+	{Input: "true + false; 5", Expected: "ERROR: unknown operator: BOOLEAN + BOOLEAN @ 1:1-1:13"},
+	{Input: "if (10 > 1) { if (true) { return true + false; } 1 }", Expected: "ERROR: unknown operator: BOOLEAN + BOOLEAN @ 1:34-1:46"},
+	{Input: "10 / (5 - 5)", Expected: "ERROR: division by zero @ 1:1-1:13"},
+	{Input: `let f = fn(n) { n - "a" }; f(1)`, Expected: "ERROR: type mismatch: INTEGER - STRING @ 1:17-1:24"},
+	{Input: "1 + 2 + true", Expected: "ERROR: type mismatch: INTEGER + BOOLEAN @ 1:1-1:13"},
+	{Input: "let f = fn() { if (1 < 2) { y } }; f()", Expected: "ERROR: identifier not found: y @ 1:29-1:30"},
+	{Input: "100000000000000000000 / 0", Expected: "ERROR: division by zero @ 1:1-1:26"},
+	{Input: "foobar", Expected: "ERROR: identifier not found: foobar @ 1:1-1:7"},
+	{Input: "let f = fn() { y }; f()", Expected: "ERROR: identifier not found: y @ 1:16-1:17"},
+	{Input: `"a" - "b"`, Expected: "ERROR: unknown operator: STRING - STRING @ 1:1-1:10"},
+	{Input: "let f = fn(x) { x }; f(1, 2)", Expected: "ERROR: wrong number of arguments: want=1, got=2 @ 1:22-1:29"},
+	{Input: "let x = 5; x(1)", Expected: "ERROR: not a function: INTEGER @ 1:12-1:16"},
+	{Input: "len(1)", Expected: "ERROR: argument to `len` not supported, got INTEGER @ 1:1-1:7"},
+	{Input: "len(1, 2)", Expected: "ERROR: wrong number of arguments to `len`: want=1, got=2 @ 1:1-1:10"},
+	{Input: "[1, 2][2]", Expected: "ERROR: index out of range: 2 with length 2 @ 1:1-1:10"},
+	{Input: "[1, 2][2:1]", Expected: "ERROR: slice bounds out of range: [2:1] with length 2 @ 1:1-1:12"},
+	{Input: "1[0]", Expected: "ERROR: index operator not supported: INTEGER @ 1:1-1:5"},
+	{Input: `{"a": 1}[fn() {}]`, Expected: "ERROR: unusable as hash key: FUNCTION @ 1:1-1:18"},
+	{Input: "{if (true) { 2.5 }: 1}", Expected: "ERROR: unusable as hash key: FLOAT @ 1:2-1:19"},
+	{Input: `{"a": 1, [1]: puts(1)}`, Expected: "ERROR: unusable as hash key: ARRAY @ 1:10-1:13"},
+	{Input: "let f = fn(n) { if (n == 0) { 1 + true } else { f(n - 1) } }; f(3)", Expected: "ERROR: type mismatch: INTEGER + BOOLEAN @ 1:31-1:39"},
+	{Input: "let f = fn(n) { 1 + f(n + 1) }; f(0)", Expected: "ERROR: stack overflow @ 1:21-1:29"},
+	{Input: `puts(1); 1 + true; puts(2)`, Expected: "ERROR: type mismatch: INTEGER + BOOLEAN @ 1:10-1:18", Output: "1\n"},
+}
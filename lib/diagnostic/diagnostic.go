@@ -0,0 +1,165 @@
+package diagnostic
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/hellozee/monkey/lib/object"
+	"github.com/hellozee/monkey/lib/parser"
+)
+
+const (
+	reset  = "\x1b[0m"
+	bold   = "\x1b[1m"
+	red    = "\x1b[31m"
+	yellow = "\x1b[33m"
+	blue   = "\x1b[34m"
+	cyan   = "\x1b[36m"
+)
+
+// Diagnostic is a problem to report against a span of source, it is
+// built from either a parse error or a runtime error object.
+type Diagnostic struct {
+	Severity string
+	Code     string
+	Message  string
+	Hint     string
+	Pos      parser.Position
+	End      parser.Position
+}
+
+func FromParseError(err *parser.ParseError) Diagnostic {
+	return Diagnostic{
+		Severity: err.Severity.String(),
+		Code:     string(err.Code),
+		Message:  err.Message,
+		Hint:     err.Hint,
+		Pos:      err.Pos,
+		End:      err.End,
+	}
+}
+
+func FromRuntimeError(err *object.Error) Diagnostic {
+	return Diagnostic{
+		Severity: "error",
+		Message:  err.Message,
+		Pos:      err.Pos,
+		End:      err.End,
+	}
+}
+
+// Printer renders diagnostics against the source they were found in,
+// quoting the offending line with the span underlined by carets.
+type Printer struct {
+	filename string
+	lines    []string
+	color    bool
+}
+
+// NewPrinter returns a printer for diagnostics in source, without the
+// source only their location is printed.
+func NewPrinter(filename, source string, color bool) *Printer {
+	p := &Printer{filename: filename, color: color}
+	if source != "" {
+		p.lines = strings.Split(source, "\n")
+	}
+	return p
+}
+
+func (p *Printer) Print(w io.Writer, d Diagnostic) {
+	severitycolor := red
+	if d.Severity == "warning" {
+		severitycolor = yellow
+	}
+
+	header := d.Severity
+	if d.Code != "" {
+		header += "[" + d.Code + "]"
+	}
+
+	fmt.Fprintf(w, "%s: %s\n", p.paint(bold+severitycolor, header), p.paint(bold, d.Message))
+
+	if d.Pos.Line == 0 {
+		p.printhint(w, d, "")
+		return
+	}
+
+	number := strconv.Itoa(d.Pos.Line)
+	gutter := strings.Repeat(" ", len(number))
+
+	fmt.Fprintf(w, "%s%s %s:%s\n", gutter, p.paint(blue, "-->"), p.filename, d.Pos)
+
+	if d.Pos.Line > len(p.lines) {
+		p.printhint(w, d, gutter)
+		return
+	}
+
+	line := strings.TrimRight(p.lines[d.Pos.Line-1], "\r")
+
+	fmt.Fprintf(w, "%s %s\n", gutter, p.paint(blue, "|"))
+	fmt.Fprintf(w, "%s %s %s\n", p.paint(blue, number), p.paint(blue, "|"), line)
+	fmt.Fprintf(w, "%s %s %s\n", gutter, p.paint(blue, "|"), p.paint(bold+severitycolor, underline(line, d)))
+
+	p.printhint(w, d, gutter)
+}
+
+func (p *Printer) PrintAll(w io.Writer, errors parser.ErrorList) {
+	for _, err := range errors {
+		p.Print(w, FromParseError(err))
+	}
+}
+
+func (p *Printer) printhint(w io.Writer, d Diagnostic, gutter string) {
+	if d.Hint == "" {
+		return
+	}
+	fmt.Fprintf(w, "%s %s %s\n", gutter, p.paint(blue, "="), p.paint(cyan, "hint: "+d.Hint))
+}
+
+func (p *Printer) paint(code, text string) string {
+	if !p.color || text == "" {
+		return text
+	}
+	return code + text + reset
+}
+
+// underline returns the caret line for d, tabs before the span are kept
+// so the carets line up with the quoted source. Columns count runes.
+func underline(line string, d Diagnostic) string {
+	runes := []rune(line)
+	start := d.Pos.Column - 1
+	if start > len(runes) {
+		start = len(runes)
+	}
+	if start < 0 {
+		start = 0
+	}
+
+	// Spans from compiled files aren't checked against the source, the
+	// carets stop at the end of the line.
+	width := 1
+	if d.End.Line == d.Pos.Line && d.End.Column > d.Pos.Column {
+		width = d.End.Column - d.Pos.Column
+	}
+	if width > len(runes)-start {
+		width = len(runes) - start
+	}
+	if width < 1 {
+		width = 1
+	}
+
+	var out strings.Builder
+
+	for _, char := range runes[:start] {
+		if char == '\t' {
+			out.WriteByte('\t')
+		} else {
+			out.WriteByte(' ')
+		}
+	}
+
+	out.WriteString(strings.Repeat("^", width))
+	return out.String()
+}
@@ -0,0 +1,174 @@
+package diagnostic
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/hellozee/monkey/lib/evaluator"
+	"github.com/hellozee/monkey/lib/object"
+	"github.com/hellozee/monkey/lib/parser"
+)
+
+func TestPrintParseError(t *testing.T) {
+	input := "let a = 1;\nlet x = (a + 2;"
+
+	p := parser.NewParser(input)
+	p.Parse()
+
+	if len(p.Errors()) == 0 {
+		t.Fatalf("expected parse errors for %q", input)
+	}
+
+	var out bytes.Buffer
+	NewPrinter("script.mk", input, false).Print(&out, FromParseError(p.Errors()[0]))
+
+	expected := "error[E0001]: expected next token is ), got ; instead\n" +
+		" --> script.mk:2:15\n" +
+		"  |\n" +
+		"2 | let x = (a + 2;\n" +
+		"  |               ^\n" +
+		"  = hint: did you forget a closing `)`?\n"
+
+	if out.String() != expected {
+		t.Errorf("wrong output.\nexpected:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
+
+func TestPrintRuntimeError(t *testing.T) {
+	input := "let f = fn(a) {\n\tmissing + a\n};\nf(1)"
+
+	p := parser.NewParser(input)
+	prog := p.Parse()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	errobj, ok := evaluator.Eval(prog, object.NewEnvironment()).(*object.Error)
+	if !ok {
+		t.Fatalf("evaluation did not produce an error")
+	}
+
+	var out bytes.Buffer
+	NewPrinter("script.mk", input, false).Print(&out, FromRuntimeError(errobj))
+
+	expected := "error: identifier not found: missing\n" +
+		" --> script.mk:2:2\n" +
+		"  |\n" +
+		"2 | \tmissing + a\n" +
+		"  | \t^^^^^^^\n"
+
+	if out.String() != expected {
+		t.Errorf("wrong output.\nexpected:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
+
+// Spans read from a compiled file may not fit the source.
+func TestPrintBadSpan(t *testing.T) {
+	tests := []struct {
+		pos, end parser.Position
+		expected string
+	}{
+		{parser.Position{Line: 1, Column: 0}, parser.Position{Line: 1, Column: 3}, "  | ^^^\n"},
+		{parser.Position{Line: 1, Column: 3}, parser.Position{Line: 1, Column: math.MaxInt32}, "  |   ^^^^^^\n"},
+		{parser.Position{Line: 1, Column: 9}, parser.Position{Line: 1, Column: 12}, "  |         ^\n"},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		NewPrinter("script.mk", "1 + true", false).Print(&out, Diagnostic{Severity: "error", Message: "bad", Pos: tt.pos, End: tt.end})
+
+		if !strings.HasSuffix(out.String(), tt.expected) {
+			t.Errorf("%s-%s wrong underline. expected=%q, got=%q", tt.pos, tt.end, tt.expected, out.String())
+		}
+	}
+}
+
+func TestPrintMultibyte(t *testing.T) {
+	input := `let grüße = "héllo" + größe;`
+
+	p := parser.NewParser(input)
+	prog := p.Parse()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	errobj, ok := evaluator.Eval(prog, object.NewEnvironment()).(*object.Error)
+	if !ok {
+		t.Fatalf("evaluation did not produce an error")
+	}
+
+	var out bytes.Buffer
+	NewPrinter("script.mk", input, false).Print(&out, FromRuntimeError(errobj))
+
+	expected := "error: identifier not found: größe\n" +
+		" --> script.mk:1:23\n" +
+		"  |\n" +
+		"1 | let grüße = \"héllo\" + größe;\n" +
+		"  |                       ^^^^^\n"
+
+	if out.String() != expected {
+		t.Errorf("wrong output.\nexpected:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
+
+func TestPrintColor(t *testing.T) {
+	d := Diagnostic{
+		Severity: "warning",
+		Code:     "E0001",
+		Message:  "something odd",
+		Hint:     "look closer",
+		Pos:      parser.Position{Line: 1, Column: 1},
+		End:      parser.Position{Line: 1, Column: 4},
+	}
+
+	var plain, colored bytes.Buffer
+	NewPrinter("x.mk", "odd", false).Print(&plain, d)
+	NewPrinter("x.mk", "odd", true).Print(&colored, d)
+
+	if strings.Contains(plain.String(), "\x1b[") {
+		t.Errorf("plain output contains escape codes. got=%q", plain.String())
+	}
+
+	if !strings.Contains(colored.String(), bold+yellow+"warning[E0001]"+reset) {
+		t.Errorf("colored output does not highlight the severity. got=%q", colored.String())
+	}
+
+	if !strings.Contains(colored.String(), cyan+"hint: look closer"+reset) {
+		t.Errorf("colored output does not highlight the hint. got=%q", colored.String())
+	}
+}
+
+func TestPrintWithoutSource(t *testing.T) {
+	tests := []struct {
+		d        Diagnostic
+		expected string
+	}{
+		{
+			Diagnostic{Severity: "error", Message: "boom"},
+			"error: boom\n",
+		},
+		{
+			Diagnostic{Severity: "error", Message: "past the end", Pos: parser.Position{Line: 9, Column: 1}},
+			"error: past the end\n --> x.mk:9:1\n",
+		},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		NewPrinter("x.mk", "one line", false).Print(&out, tt.d)
+
+		if out.String() != tt.expected {
+			t.Errorf("wrong output. expected=%q, got=%q", tt.expected, out.String())
+		}
+	}
+
+	var out bytes.Buffer
+	d := Diagnostic{Severity: "error", Message: "no source", Pos: parser.Position{Line: 1, Column: 1}}
+	NewPrinter("x.mkc", "", false).Print(&out, d)
+
+	if expected := "error: no source\n --> x.mkc:1:1\n"; out.String() != expected {
+		t.Errorf("wrong output. expected=%q, got=%q", expected, out.String())
+	}
+}
@@ -0,0 +1,206 @@
+// Package disasm prints compiled Monkey programs in a readable form: the
+// instructions of every function with decoded operands, annotated with
+// the source lines that produced them, and the constant pool.
+package disasm
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/hellozee/monkey/lib/code"
+	"github.com/hellozee/monkey/lib/compiler"
+	"github.com/hellozee/monkey/lib/object"
+)
+
+type Printer struct {
+	filename string
+	lines    []string
+}
+
+// NewPrinter returns a printer for programs compiled from source, the
+// source may be empty when it isn't available.
+func NewPrinter(filename, source string) *Printer {
+	p := &Printer{filename: filename}
+	if source != "" {
+		p.lines = strings.Split(source, "\n")
+	}
+	return p
+}
+
+// listing is what Print needs to know about the program it is printing.
+type listing struct {
+	bytecode *compiler.Bytecode
+	parents  map[*object.CompiledFunction]*object.CompiledFunction
+}
+
+func (p *Printer) Print(w io.Writer, bytecode *compiler.Bytecode) {
+	l := &listing{bytecode: bytecode, parents: parents(bytecode)}
+
+	fmt.Fprintf(w, "== %s ==\n", p.filename)
+	p.printfunction(w, l, bytecode.Main)
+
+	if len(bytecode.Constants) == 0 {
+		return
+	}
+
+	fmt.Fprintf(w, "\n== constants ==\n")
+	for i, constant := range bytecode.Constants {
+		fmt.Fprintf(w, "%5d  %-17s %s\n", i, constant.Type(), describe(constant))
+	}
+
+	for i, constant := range bytecode.Constants {
+		fn, ok := constant.(*object.CompiledFunction)
+		if !ok {
+			continue
+		}
+
+		fmt.Fprintf(w, "\n== constant %d: %s ==\n", i, fn.Source)
+		locals := strings.Join(fn.Locals, ", ")
+		if locals == "" {
+			locals = "none"
+		}
+		fmt.Fprintf(w, "parameters: %d, locals: %s\n", fn.NumParameters, locals)
+		p.printfunction(w, l, fn)
+	}
+}
+
+// printfunction lists the instructions of fn, every time the source line
+// changes the line is printed above the instructions it produced.
+func (p *Printer) printfunction(w io.Writer, l *listing, fn *object.CompiledFunction) {
+	ins := fn.Instructions
+	line := 0
+
+	for offset := 0; offset < len(ins); {
+		if pos, ok := fn.Positions.Lookup(offset); ok && pos.Pos.Line != line {
+			line = pos.Pos.Line
+			fmt.Fprintln(w, strings.TrimRight(fmt.Sprintf("%5d | %s", line, p.source(line)), " "))
+		}
+
+		def, err := code.Lookup(ins[offset])
+		if err != nil {
+			fmt.Fprintf(w, "      %04d  ERROR: %s\n", offset, err)
+			offset++
+			continue
+		}
+
+		operands, read := code.ReadOperands(def, ins[offset+1:])
+		text := code.FormatInstruction(def, operands)
+
+		if comment := l.comment(fn, code.Opcode(ins[offset]), operands); comment != "" {
+			fmt.Fprintf(w, "      %04d  %-24s ; %s\n", offset, text, comment)
+		} else {
+			fmt.Fprintf(w, "      %04d  %s\n", offset, text)
+		}
+
+		offset += 1 + read
+	}
+}
+
+func (p *Printer) source(line int) string {
+	if line < 1 || line > len(p.lines) {
+		return ""
+	}
+	return strings.TrimRight(p.lines[line-1], " \t\r")
+}
+
+// comment explains the operands of an instruction in fn.
+func (l *listing) comment(fn *object.CompiledFunction, op code.Opcode, operands []int) string {
+	switch op {
+	case code.OpConstant, code.OpClosure:
+		if operands[0] < len(l.bytecode.Constants) {
+			return describe(l.bytecode.Constants[operands[0]])
+		}
+	case code.OpGetGlobal, code.OpSetGlobal:
+		if operands[0] < len(l.bytecode.Globals) {
+			return l.bytecode.Globals[operands[0]]
+		}
+	case code.OpGetLocal, code.OpSetLocal:
+		if operands[0] < len(fn.Locals) {
+			return fn.Locals[operands[0]]
+		}
+	case code.OpGetOuter:
+		outer := fn
+		for i := 0; i < operands[0] && outer != nil; i++ {
+			outer = l.parents[outer]
+		}
+		if outer != nil && operands[1] < len(outer.Locals) {
+			return outer.Locals[operands[1]]
+		}
+	case code.OpInfixLocalConstant:
+		if operands[0] < len(fn.Locals) && operands[1] < len(l.bytecode.Constants) {
+			constant := describe(l.bytecode.Constants[operands[1]])
+			return fmt.Sprintf("%s %s %s", fn.Locals[operands[0]], code.InfixOperators[code.Opcode(operands[2])], constant)
+		}
+	case code.OpJump, code.OpJumpNotTruthy:
+		return fmt.Sprintf("to %04d", operands[0])
+	case code.OpSlice:
+		bounds := []string{}
+		if operands[0]&code.SliceLow != 0 {
+			bounds = append(bounds, "low")
+		}
+		if operands[0]&code.SliceHigh != 0 {
+			bounds = append(bounds, "high")
+		}
+		return "[" + strings.Join(bounds, ":") + "]"
+	case code.OpCall:
+		return count(operands[0], "argument")
+	case code.OpArray:
+		return count(operands[0], "element")
+	case code.OpHash:
+		return count(operands[0], "pair")
+	}
+	return ""
+}
+
+func count(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// parents finds the function each compiled function is created in, the
+// names of outer variables are in the parent's locals.
+func parents(bytecode *compiler.Bytecode) map[*object.CompiledFunction]*object.CompiledFunction {
+	result := make(map[*object.CompiledFunction]*object.CompiledFunction)
+	functions := []*object.CompiledFunction{bytecode.Main}
+
+	for _, constant := range bytecode.Constants {
+		if fn, ok := constant.(*object.CompiledFunction); ok {
+			functions = append(functions, fn)
+		}
+	}
+
+	for _, fn := range functions {
+		ins := fn.Instructions
+		for offset := 0; offset < len(ins); {
+			def, err := code.Lookup(ins[offset])
+			if err != nil {
+				break
+			}
+
+			operands, read := code.ReadOperands(def, ins[offset+1:])
+			if code.Opcode(ins[offset]) == code.OpClosure && operands[0] < len(bytecode.Constants) {
+				if child, ok := bytecode.Constants[operands[0]].(*object.CompiledFunction); ok {
+					result[child] = fn
+				}
+			}
+			offset += 1 + read
+		}
+	}
+
+	return result
+}
+
+// describe shows a constant the way it was written in the source.
+func describe(obj object.Object) string {
+	switch obj := obj.(type) {
+	case *object.String:
+		return fmt.Sprintf("%q", obj.Value)
+	case *object.CompiledFunction:
+		return obj.Source
+	default:
+		return obj.Inspect()
+	}
+}
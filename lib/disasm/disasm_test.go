@@ -0,0 +1,105 @@
+package disasm
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/hellozee/monkey/lib/compiler"
+	"github.com/hellozee/monkey/lib/parser"
+	"github.com/hellozee/monkey/lib/peephole"
+)
+
+func TestPrint(t *testing.T) {
+	input := `let a = "x";
+fn(b) { fn() { a + b }([b][0:], {1: b}) }`
+
+	expected := `== test.mk ==
+    1 | let a = "x";
+      0000  OpConstant 0             ; "x"
+      0003  OpSetGlobal 0            ; a
+    2 | fn(b) { fn() { a + b }([b][0:], {1: b}) }
+      0006  OpClosure 4              ; fn(b) { fn() { (a + b) }(([b][0:]), {1: b}) }
+
+== constants ==
+    0  STRING            "x"
+    1  COMPILED_FUNCTION fn() { (a + b) }
+    2  INTEGER           0
+    3  INTEGER           1
+    4  COMPILED_FUNCTION fn(b) { fn() { (a + b) }(([b][0:]), {1: b}) }
+
+== constant 1: fn() { (a + b) } ==
+parameters: 0, locals: none
+    2 | fn(b) { fn() { a + b }([b][0:], {1: b}) }
+      0000  OpGetGlobal 0            ; a
+      0003  OpGetOuter 1 0           ; b
+      0007  OpAdd
+      0008  OpReturnValue
+
+== constant 4: fn(b) { fn() { (a + b) }(([b][0:]), {1: b}) } ==
+parameters: 1, locals: b
+    2 | fn(b) { fn() { a + b }([b][0:], {1: b}) }
+      0000  OpClosure 1              ; fn() { (a + b) }
+      0003  OpGetLocal 0             ; b
+      0006  OpArray 1                ; 1 element
+      0009  OpConstant 2             ; 0
+      0012  OpSlice 1                ; [low]
+      0014  OpConstant 3             ; 1
+      0017  OpHashKey
+      0018  OpGetLocal 0             ; b
+      0021  OpHash 1                 ; 1 pair
+      0024  OpCall 2                 ; 2 arguments
+      0026  OpReturnValue
+`
+
+	p := parser.NewParser(input)
+	prog := p.Parse()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %s", p.Errors())
+	}
+
+	c := compiler.New()
+	if err := c.Compile(prog); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	var out bytes.Buffer
+	NewPrinter("test.mk", input).Print(&out, c.Bytecode())
+
+	if out.String() != expected {
+		t.Errorf("wrong listing.\nwant=\n%s\ngot=\n%s", expected, out.String())
+	}
+}
+
+func TestPrintWithoutSource(t *testing.T) {
+	c := compiler.New()
+	if err := c.Compile(parser.NewParser("true").Parse()); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	var out bytes.Buffer
+	NewPrinter("test.mkc", "").Print(&out, c.Bytecode())
+
+	expected := "== test.mkc ==\n    1 |\n      0000  OpTrue\n"
+	if out.String() != expected {
+		t.Errorf("wrong listing.\nwant=%q\ngot=%q", expected, out.String())
+	}
+}
+
+func TestPrintSuperinstruction(t *testing.T) {
+	c := compiler.New()
+	if err := c.Compile(parser.NewParser("fn(n) { n < 2 }").Parse()); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	bytecode := c.Bytecode()
+	peephole.Optimize(bytecode)
+
+	var out bytes.Buffer
+	NewPrinter("test.mk", "").Print(&out, bytecode)
+
+	expected := "      0000  OpInfixLocalConstant 0 0 9 ; n < 2\n"
+	if !strings.Contains(out.String(), expected) {
+		t.Errorf("superinstruction not explained. want=%q\ngot=\n%s", expected, out.String())
+	}
+}
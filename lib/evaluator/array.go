@@ -0,0 +1,82 @@
+package evaluator
+
+import (
+	"github.com/hellozee/monkey/lib/object"
+)
+
+func evalarrayindex(array *object.Array, index object.Object) object.Object {
+	if index.Type() != object.INTEGER {
+		return newerror("index must be an INTEGER, got %s", index.Type())
+	}
+
+	i, ok := arrayindex(index, len(array.Elements))
+	if !ok || i >= len(array.Elements) {
+		return newerror("index out of range: %s with length %d", index.Inspect(), len(array.Elements))
+	}
+
+	return array.Elements[i]
+}
+
+// evalsliceexpr returns a new array with the elements of left from low
+// up to but not including high. A nil bound stands for the start or the
+// end of the array.
+func evalsliceexpr(left, low, high object.Object) object.Object {
+	array, ok := left.(*object.Array)
+	if !ok {
+		return newerror("slice operator not supported: %s", left.Type())
+	}
+
+	length := len(array.Elements)
+	lo, hi := 0, length
+	lok, hok := true, true
+
+	for _, bound := range []object.Object{low, high} {
+		if bound != nil && bound.Type() != object.INTEGER {
+			return newerror("slice bound must be an INTEGER, got %s", bound.Type())
+		}
+	}
+
+	if low != nil {
+		lo, lok = arrayindex(low, length)
+	}
+
+	if high != nil {
+		hi, hok = arrayindex(high, length)
+	}
+
+	if !lok || !hok || hi > length || lo > hi {
+		return newerror("slice bounds out of range: [%s:%s] with length %d", inspectbound(low), inspectbound(high), length)
+	}
+
+	elements := make([]object.Object, hi-lo)
+	copy(elements, array.Elements[lo:hi])
+	return &object.Array{Elements: elements}
+}
+
+// arrayindex turns an integer object into an index into an array of the
+// given length, negative indices count from the end. It returns false
+// for indices before the start, callers check the upper bound.
+func arrayindex(index object.Object, length int) (int, bool) {
+	integer, ok := index.(*object.Integer)
+	if !ok {
+		return 0, false
+	}
+
+	i := integer.Value
+	if i < 0 {
+		i += int64(length)
+	}
+
+	if i < 0 || i > int64(length) {
+		return 0, false
+	}
+
+	return int(i), true
+}
+
+func inspectbound(bound object.Object) string {
+	if bound == nil {
+		return ""
+	}
+	return bound.Inspect()
+}
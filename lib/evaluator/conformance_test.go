@@ -0,0 +1,17 @@
+package evaluator
+
+import (
+	"testing"
+
+	"github.com/hellozee/monkey/lib/conformance"
+	"github.com/hellozee/monkey/lib/object"
+	"github.com/hellozee/monkey/lib/parser"
+)
+
+func TestConformance(t *testing.T) {
+	conformance.Run(t, func(prog *parser.Program, builtins *object.Builtins) object.Object {
+		env := object.NewEnvironment()
+		env.SetBuiltins(builtins)
+		return Eval(prog, env)
+	})
+}
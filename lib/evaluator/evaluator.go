@@ -0,0 +1,450 @@
+package evaluator
+
+import (
+	"math"
+	"math/big"
+
+	"github.com/hellozee/monkey/lib/object"
+	"github.com/hellozee/monkey/lib/parser"
+)
+
+// MaxDepth is how deep function calls can nest before evaluation fails
+// with a stack overflow, the VM uses the same limit.
+const MaxDepth = 10000
+
+var (
+	NULL  = &object.Null{}
+	TRUE  = &object.Boolean{Value: true}
+	FALSE = &object.Boolean{Value: false}
+)
+
+func Eval(node parser.Node, env *object.Environment) object.Object {
+	switch node := node.(type) {
+	case *parser.Program:
+		return evalprogram(node, env)
+	case *parser.BlockStatement:
+		return evalblockstatement(node, env)
+	case *parser.ExpressionStatement:
+		return Eval(node.Expr, env)
+	case *parser.ReturnStatement:
+		if node.Value == nil {
+			return &object.ReturnValue{Value: NULL}
+		}
+		value := Eval(node.Value, env)
+		if isabrupt(value) {
+			return value
+		}
+		return &object.ReturnValue{Value: value}
+	case *parser.IntLiteral:
+		if node.Big != nil {
+			return normalize(node.Big)
+		}
+		return &object.Integer{Value: node.Value}
+	case *parser.FloatLiteral:
+		return &object.Float{Value: node.Value}
+	case *parser.StringLiteral:
+		return &object.String{Value: node.Value}
+	case *parser.BoolExpr:
+		return nativebool(node.Value)
+	case *parser.PrefixExpr:
+		right := Eval(node.Right, env)
+		if isabrupt(right) {
+			return right
+		}
+		return locate(evalprefixexpr(node.Operator, right), node)
+	case *parser.InfixExpr:
+		left := Eval(node.Left, env)
+		if isabrupt(left) {
+			return left
+		}
+		right := Eval(node.Right, env)
+		if isabrupt(right) {
+			return right
+		}
+		return locate(evalinfixexpr(node.Operator, left, right), node)
+	case *parser.IfExpr:
+		return evalifexpr(node, env)
+	case *parser.BadStatement:
+		return locate(newerror("cannot evaluate a statement that failed to parse"), node)
+	case *parser.LetStatement:
+		value := Eval(node.Value, env)
+		if isabrupt(value) {
+			return value
+		}
+		env.Set(node.Name.Value, value)
+	case *parser.Identifier:
+		return locate(evalidentifier(node, env), node)
+	case *parser.FnLiteral:
+		return &object.Function{Parameters: node.Parameters, Body: node.Body, Env: env, Original: node.Original}
+	case *parser.CallExpr:
+		function := Eval(node.Function, env)
+		if isabrupt(function) {
+			return function
+		}
+
+		args := evalexpressions(node.Arguments, env)
+		if len(args) == 1 && isabrupt(args[0]) {
+			return args[0]
+		}
+
+		return locate(applyfunction(function, args, env.Depth()+1), node)
+	case *parser.ArrayLiteral:
+		elements := evalexpressions(node.Elements, env)
+		if len(elements) == 1 && isabrupt(elements[0]) {
+			return elements[0]
+		}
+		return &object.Array{Elements: elements}
+	case *parser.IndexExpr:
+		left := Eval(node.Left, env)
+		if isabrupt(left) {
+			return left
+		}
+		index := Eval(node.Index, env)
+		if isabrupt(index) {
+			return index
+		}
+		return locate(evalindexexpr(left, index), node)
+	case *parser.SliceExpr:
+		return evalslice(node, env)
+	case *parser.HashLiteral:
+		return evalhashliteral(node, env)
+	}
+
+	return nil
+}
+
+// evalprogram returns the value of the last statement, programs that are
+// empty or end in a let have none and return nil like the VM does.
+func evalprogram(prog *parser.Program, env *object.Environment) object.Object {
+	var result object.Object
+
+	for _, stmt := range prog.Statements {
+		result = Eval(stmt, env)
+
+		switch result := result.(type) {
+		case *object.ReturnValue:
+			return result.Value
+		case *object.Error:
+			return result
+		}
+	}
+
+	return result
+}
+
+func evalblockstatement(block *parser.BlockStatement, env *object.Environment) object.Object {
+	var result object.Object = NULL
+
+	for _, stmt := range block.Statements {
+		result = Eval(stmt, env)
+
+		if result != nil {
+			rt := result.Type()
+			if rt == object.RETURN || rt == object.ERROR {
+				return result
+			}
+		}
+	}
+
+	// a block ending in a let has no value of its own
+	if result == nil {
+		return NULL
+	}
+
+	return result
+}
+
+func evalprefixexpr(operator string, right object.Object) object.Object {
+	switch operator {
+	case "!":
+		return evalbangoperator(right)
+	case "-":
+		return evalminusprefixoperator(right)
+	default:
+		return newerror("unknown operator: %s%s", operator, right.Type())
+	}
+}
+
+func evalbangoperator(right object.Object) object.Object {
+	return nativebool(!istruthy(right))
+}
+
+func evalminusprefixoperator(right object.Object) object.Object {
+	switch right := right.(type) {
+	case *object.Integer:
+		if right.Value == math.MinInt64 {
+			return normalize(new(big.Int).Neg(tobig(right)))
+		}
+		return &object.Integer{Value: -right.Value}
+	case *object.BigInteger:
+		return normalize(new(big.Int).Neg(right.Value))
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	default:
+		return newerror("unknown operator: -%s", right.Type())
+	}
+}
+
+func evalinfixexpr(operator string, left, right object.Object) object.Object {
+	switch {
+	case left.Type() == object.INTEGER && right.Type() == object.INTEGER:
+		return evalintegerinfixexpr(operator, left, right)
+	case isnumber(left) && isnumber(right):
+		return evalfloatinfixexpr(operator, left, right)
+	case left.Type() == object.STRING && right.Type() == object.STRING:
+		return evalstringinfixexpr(operator, left, right)
+	case left.Type() != right.Type():
+		return newerror("type mismatch: %s %s %s", left.Type(), operator, right.Type())
+	case operator == "==":
+		return nativebool(equal(left, right))
+	case operator == "!=":
+		return nativebool(!equal(left, right))
+	default:
+		return newerror("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// evalfloatinfixexpr handles arithmetic where at least one operand is a
+// float, integers are promoted to float and the result is always a float.
+func evalfloatinfixexpr(operator string, left, right object.Object) object.Object {
+	lval := tofloat(left)
+	rval := tofloat(right)
+
+	switch operator {
+	case "+":
+		return &object.Float{Value: lval + rval}
+	case "-":
+		return &object.Float{Value: lval - rval}
+	case "*":
+		return &object.Float{Value: lval * rval}
+	case "/":
+		if rval == 0 {
+			return newerror("division by zero")
+		}
+		return &object.Float{Value: lval / rval}
+	case "<":
+		return nativebool(lval < rval)
+	case ">":
+		return nativebool(lval > rval)
+	case "==":
+		return nativebool(lval == rval)
+	case "!=":
+		return nativebool(lval != rval)
+	default:
+		return newerror("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+func isnumber(obj object.Object) bool {
+	return obj.Type() == object.INTEGER || obj.Type() == object.FLOAT
+}
+
+func tofloat(obj object.Object) float64 {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return float64(obj.Value)
+	case *object.BigInteger:
+		value, _ := new(big.Float).SetInt(obj.Value).Float64()
+		return value
+	case *object.Float:
+		return obj.Value
+	}
+	return 0
+}
+
+func evalstringinfixexpr(operator string, left, right object.Object) object.Object {
+	lval := left.(*object.String).Value
+	rval := right.(*object.String).Value
+
+	switch operator {
+	case "+":
+		return &object.String{Value: lval + rval}
+	case "==":
+		return nativebool(lval == rval)
+	case "!=":
+		return nativebool(lval != rval)
+	default:
+		return newerror("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+func evalifexpr(ie *parser.IfExpr, env *object.Environment) object.Object {
+	condition := Eval(ie.Condition, env)
+	if isabrupt(condition) {
+		return condition
+	}
+
+	if istruthy(condition) {
+		return Eval(ie.Consequence, env)
+	} else if ie.Alternative != nil {
+		return Eval(ie.Alternative, env)
+	}
+
+	return NULL
+}
+
+func evalindexexpr(left, index object.Object) object.Object {
+	switch left := left.(type) {
+	case *object.Array:
+		return evalarrayindex(left, index)
+	case *object.Hash:
+		return evalhashindex(left, index)
+	default:
+		return newerror("index operator not supported: %s", left.Type())
+	}
+}
+
+func evalslice(node *parser.SliceExpr, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isabrupt(left) {
+		return left
+	}
+
+	var low, high object.Object
+
+	if node.Low != nil {
+		low = Eval(node.Low, env)
+		if isabrupt(low) {
+			return low
+		}
+	}
+
+	if node.High != nil {
+		high = Eval(node.High, env)
+		if isabrupt(high) {
+			return high
+		}
+	}
+
+	return locate(evalsliceexpr(left, low, high), node)
+}
+
+// evalidentifier looks name up in the environment and then among the
+// builtins, so scripts can shadow a builtin with their own binding.
+func evalidentifier(node *parser.Identifier, env *object.Environment) object.Object {
+	if value, ok := env.Get(node.Value); ok {
+		return value
+	}
+
+	if builtins := env.Builtins(); builtins != nil {
+		if builtin, ok := builtins.Lookup(node.Value); ok {
+			return builtin
+		}
+	}
+
+	return newerror("identifier not found: %s", node.Value)
+}
+
+func evalexpressions(exprs []parser.Expression, env *object.Environment) []object.Object {
+	var result []object.Object
+
+	for _, e := range exprs {
+		evaluated := Eval(e, env)
+		if isabrupt(evaluated) {
+			return []object.Object{evaluated}
+		}
+		result = append(result, evaluated)
+	}
+
+	return result
+}
+
+// applyfunction calls fn with args, depth is the number of calls the
+// body runs in.
+func applyfunction(fn object.Object, args []object.Object, depth int) object.Object {
+	switch function := fn.(type) {
+	case *object.Function:
+		if len(args) != len(function.Parameters) {
+			return newerror("wrong number of arguments: want=%d, got=%d", len(function.Parameters), len(args))
+		}
+
+		if depth > MaxDepth {
+			return newerror("stack overflow")
+		}
+
+		env := extendfunctionenv(function, args)
+		env.SetDepth(depth)
+		evaluated := Eval(function.Body, env)
+		return unwrapreturnvalue(evaluated)
+	case *object.Builtin:
+		if result := function.Call(args...); result != nil {
+			return result
+		}
+		return NULL
+	default:
+		return newerror("not a function: %s", fn.Type())
+	}
+}
+
+func extendfunctionenv(fn *object.Function, args []object.Object) *object.Environment {
+	env := object.NewEnclosedEnvironment(fn.Env)
+
+	for i, param := range fn.Parameters {
+		env.Set(param.Value, args[i])
+	}
+
+	return env
+}
+
+func unwrapreturnvalue(obj object.Object) object.Object {
+	if returnvalue, ok := obj.(*object.ReturnValue); ok {
+		return returnvalue.Value
+	}
+	return obj
+}
+
+// istruthy goes by value rather than the singletons, builtins written
+// in Go may return booleans and nulls of their own.
+func istruthy(obj object.Object) bool {
+	switch obj := obj.(type) {
+	case *object.Null:
+		return false
+	case *object.Boolean:
+		return obj.Value
+	default:
+		return true
+	}
+}
+
+// equal compares booleans and nulls by value and everything else by
+// identity.
+func equal(left, right object.Object) bool {
+	switch left := left.(type) {
+	case *object.Boolean:
+		return left.Value == right.(*object.Boolean).Value
+	case *object.Null:
+		return true
+	}
+	return left == right
+}
+
+func nativebool(value bool) *object.Boolean {
+	if value {
+		return TRUE
+	}
+	return FALSE
+}
+
+func newerror(format string, a ...interface{}) *object.Error {
+	return object.NewError(format, a...)
+}
+
+// locate records the position of node on errors that don't have one yet,
+// errors raised deeper in the tree keep their more precise location.
+func locate(obj object.Object, node parser.Node) object.Object {
+	if errobj, ok := obj.(*object.Error); ok && errobj.Pos.Line == 0 {
+		errobj.Pos = node.Pos()
+		errobj.End = node.End()
+	}
+	return obj
+}
+
+// isabrupt reports whether obj ends the evaluation of the expressions
+// around it: errors propagate up to the program and return values up to
+// the function they return from.
+func isabrupt(obj object.Object) bool {
+	if obj != nil {
+		return obj.Type() == object.ERROR || obj.Type() == object.RETURN
+	}
+	return false
+}
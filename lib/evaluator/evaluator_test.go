@@ -0,0 +1,887 @@
+package evaluator
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/hellozee/monkey/lib/object"
+	"github.com/hellozee/monkey/lib/parser"
+)
+
+func TestEvalIntegerExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"5", 5},
+		{"10", 10},
+		{"-5", -5},
+		{"-10", -10},
+		{"5 + 5 + 5 + 5 - 10", 10},
+		{"2 * 2 * 2 * 2 * 2", 32},
+		{"-50 + 100 + -50", 0},
+		{"5 * 2 + 10", 20},
+		{"5 + 2 * 10", 25},
+		{"20 + 2 * -10", 0},
+		{"50 / 2 * 2 + 10", 60},
+		{"2 * (5 + 10)", 30},
+		{"3 * 3 * 3 + 10", 37},
+		{"3 * (3 * 3) + 10", 37},
+		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.14", 3.14},
+		{"-2.5", -2.5},
+		{"1.5 + 1.5", 3},
+		{"1 + 0.5", 1.5},
+		{"0.5 + 1", 1.5},
+		{"10 / 4.0", 2.5},
+		{"2 * 1e3", 2000},
+		{"0xff - 0.5", 254.5},
+		{"-(1_000 * 0.5)", -500},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		result, ok := evaluated.(*object.Float)
+		if !ok {
+			t.Errorf("%q is not Float. got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+
+		if result.Value != tt.expected {
+			t.Errorf("%q has wrong value. got=%g, want=%g", tt.input, result.Value, tt.expected)
+		}
+	}
+}
+
+func TestMixedNumberComparison(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"1 == 1.0", true},
+		{"1 != 1.5", true},
+		{"2 < 2.5", true},
+		{"2.5 > 3", false},
+	}
+
+	for _, tt := range tests {
+		testBoolObject(t, testEval(t, tt.input), tt.expected)
+	}
+}
+
+func TestIntegerDivisionStaysInteger(t *testing.T) {
+	testIntegerObject(t, testEval(t, "7 / 2"), 3)
+	testIntegerObject(t, testEval(t, "0b1010 + 0o17 + 1_000"), 1025)
+}
+
+func TestBigIntegers(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		big      bool
+	}{
+		{"9223372036854775807 + 1", "9223372036854775808", true},
+		{"-9223372036854775807 - 2", "-9223372036854775809", true},
+		{"9223372036854775807 * 9223372036854775807", "85070591730234615847396907784232501249", true},
+		{"-(-9223372036854775807 - 1)", "9223372036854775808", true},
+		{"(-9223372036854775807 - 1) / -1", "9223372036854775808", true},
+		{"123456789012345678901234567890 * 10", "1234567890123456789012345678900", true},
+		{"(9223372036854775807 + 1) - 1", "9223372036854775807", false},
+		{"123456789012345678901234567890 / 123456789012345678901234567890", "1", false},
+		{"-7000000000000000000000 / 1000000000000000000000", "-7", false},
+		{"let cents = 100000000000000000000; cents * cents / cents", "100000000000000000000", true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		if evaluated.Type() != object.INTEGER {
+			t.Errorf("%q is not INTEGER. got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+
+		if _, isbig := evaluated.(*object.BigInteger); isbig != tt.big {
+			t.Errorf("%q has wrong representation. big expected=%t, got=%T", tt.input, tt.big, evaluated)
+		}
+
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%q has wrong value. expected=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestBigIntegerComparison(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"9223372036854775807 + 1 > 9223372036854775807", true},
+		{"100000000000000000000 == 100000000000000000000", true},
+		{"100000000000000000000 != 100000000000000000001", true},
+		{"-100000000000000000000 < 1", true},
+		{"100000000000000000000 == 1e20", true},
+	}
+
+	for _, tt := range tests {
+		testBoolObject(t, testEval(t, tt.input), tt.expected)
+	}
+
+	evaluated := testEval(t, "100000000000000000000 / 0")
+	if errobj, ok := evaluated.(*object.Error); !ok || errobj.Message != "division by zero" {
+		t.Errorf("big division by zero not reported. got=%T (%+v)", evaluated, evaluated)
+	}
+}
+
+func TestFloatInspect(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"2.0", "2.0"},
+		{"1.5", "1.5"},
+		{"1e21", "1e+21"},
+		{"0.1 + 0.2", "0.30000000000000004"},
+	}
+
+	for _, tt := range tests {
+		if inspected := testEval(t, tt.input).Inspect(); inspected != tt.expected {
+			t.Errorf("%q inspects wrong. expected=%q, got=%q", tt.input, tt.expected, inspected)
+		}
+	}
+}
+
+func TestEvalBoolExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"true", true},
+		{"false", false},
+		{"1 < 2", true},
+		{"1 > 2", false},
+		{"1 < 1", false},
+		{"1 > 1", false},
+		{"1 == 1", true},
+		{"1 != 1", false},
+		{"1 == 2", false},
+		{"1 != 2", true},
+		{"true == true", true},
+		{"false == false", true},
+		{"true == false", false},
+		{"true != false", true},
+		{"false != true", true},
+		{"(1 < 2) == true", true},
+		{"(1 < 2) == false", false},
+		{"(1 > 2) == true", false},
+		{"(1 > 2) == false", true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		testBoolObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestBangOperator(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"!true", false},
+		{"!false", true},
+		{"!5", false},
+		{"!!true", true},
+		{"!!false", false},
+		{"!!5", true},
+		{"!0", false},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		testBoolObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestIfElseExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"if (true) { 10 }", 10},
+		{"if (false) { 10 }", nil},
+		{"if (1) { 10 }", 10},
+		{"if (1 < 2) { 10 }", 10},
+		{"if (1 > 2) { 10 }", nil},
+		{"if (1 > 2) { 10 } else { 20 }", 20},
+		{"if (1 < 2) { 10 } else { 20 }", 10},
+		{"if (1 > 2) { 10 } else if (2 > 1) { 30 } else { 20 }", 30},
+		{"if (true) {}", nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestReturnStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"return 10;", 10},
+		{"return 10; 9;", 10},
+		{"return 2 * 5; 9;", 10},
+		{"9; return 2 * 5; 9;", 10},
+		{"return;", nil},
+		{`if (10 > 1) {
+			if (10 > 1) {
+				return 10;
+			}
+
+			return 1;
+		}`, 10},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+// A return inside an if used as a value leaves the function right away,
+// whatever expression the if is part of.
+func TestReturnInsideExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let f = fn() { let x = if (true) { return 1 }; 2 }; f()", 1},
+		{"-if (true) { return 1 }", 1},
+		{"!if (true) { return 1 }", 1},
+		{"fn(x) { if (x) { return 1 } + 1 }(true)", 1},
+		{"fn(x) { 1 + if (x) { return 1 } }(true)", 1},
+		{"[if (true) { return 1 }, 5]", 1},
+		{"{if (true) { return 1 }: 5}", 1},
+		{"{5: if (true) { return 1 }}", 1},
+		{"[5][if (true) { return 1 }]", 1},
+		{"[5][0:if (true) { return 1 }]", 1},
+		{"len(if (true) { return 1 })", 1},
+		{"if (if (true) { return 1 }) { 2 }", 1},
+		{"fn() { return if (true) { return 1 } else { 2 } }()", 1},
+		{"let f = fn() { let g = fn() { [if (true) { return 1 }] }; g() + 1 }; f()", 2},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(t, tt.input), tt.expected)
+	}
+}
+
+func TestErrorHandling(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"5 + true;", "type mismatch: INTEGER + BOOLEAN"},
+		{"5 + true; 5;", "type mismatch: INTEGER + BOOLEAN"},
+		{"-true", "unknown operator: -BOOLEAN"},
+		{"true + false;", "unknown operator: BOOLEAN + BOOLEAN"},
+		{"5; true + false; 5", "unknown operator: BOOLEAN + BOOLEAN"},
+		{"if (10 > 1) { true + false; }", "unknown operator: BOOLEAN + BOOLEAN"},
+		{`if (10 > 1) {
+			if (10 > 1) {
+				return true + false;
+			}
+
+			return 1;
+		}`, "unknown operator: BOOLEAN + BOOLEAN"},
+		{"10 / (5 - 5)", "division by zero"},
+		{"1.5 / 0", "division by zero"},
+		{"1.5 + true", "type mismatch: FLOAT + BOOLEAN"},
+		{"foobar", "identifier not found: foobar"},
+		{`"Hello" - "World"`, "unknown operator: STRING - STRING"},
+		{`"a" + 1`, "type mismatch: STRING + INTEGER"},
+		{"let f = fn(x) { x }; f(1, 2)", "wrong number of arguments: want=1, got=2"},
+		{"let x = 5; x(1)", "not a function: INTEGER"},
+		{"let f = fn() { y }; f()", "identifier not found: y"},
+		{"[1, 2, 3][3]", "index out of range: 3 with length 3"},
+		{"[1, 2, 3][-4]", "index out of range: -4 with length 3"},
+		{"[][0]", "index out of range: 0 with length 0"},
+		{"[1][100000000000000000000]", "index out of range: 100000000000000000000 with length 1"},
+		{`[1]["0"]`, "index must be an INTEGER, got STRING"},
+		{"1[0]", "index operator not supported: INTEGER"},
+		{"[1, 2, 3][2:1]", "slice bounds out of range: [2:1] with length 3"},
+		{"[1, 2, 3][:4]", "slice bounds out of range: [:4] with length 3"},
+		{"[1, 2, 3][-5:]", "slice bounds out of range: [-5:] with length 3"},
+		{"[1, 2, 3][1.5:]", "slice bound must be an INTEGER, got FLOAT"},
+		{`"abc"[0:1]`, "slice operator not supported: STRING"},
+		{"[1, x, 3]", "identifier not found: x"},
+		{`{"name": "Monkey"}[fn(x) { x }];`, "unusable as hash key: FUNCTION"},
+		{`{fn(x) { x }: 1}`, "unusable as hash key: FUNCTION"},
+		{`{[1]: 1}`, "unusable as hash key: ARRAY"},
+		{`{1.5: 1}`, "unusable as hash key: FLOAT"},
+		{`{"a": x}`, "identifier not found: x"},
+		{`{"a": 1}[1:]`, "slice operator not supported: HASH"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		errobj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+
+		if errobj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expectedMessage, errobj.Message)
+		}
+	}
+}
+
+func TestBadStatement(t *testing.T) {
+	p := parser.NewParser("let a = 1;\nlet = 2;\na")
+	prog := p.Parse()
+
+	if len(p.Errors()) != 1 {
+		t.Fatalf("expected 1 parser error. got=%q", p.Errors())
+	}
+
+	errobj, ok := Eval(prog, object.NewEnvironment()).(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned")
+	}
+
+	if errobj.Message != "cannot evaluate a statement that failed to parse" || errobj.Pos.String() != "2:1" {
+		t.Errorf("wrong error. got=%s %q", errobj.Pos, errobj.Message)
+	}
+}
+
+func TestErrorPositions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"5 + true;", "1:1"},
+		{"let x = 1;\nlet y = -true;", "2:9"},
+		{"let f = fn() {\n  missing\n};\nf()", "2:3"},
+		{"let x = 1;\n  x(2)", "2:3"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		errobj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+
+		if errobj.Pos.String() != tt.expected {
+			t.Errorf("wrong error position for %q. expected=%s, got=%s", tt.input, tt.expected, errobj.Pos)
+		}
+	}
+}
+
+func TestFunctionObject(t *testing.T) {
+	input := "fn(x) { x + 2; };"
+
+	evaluated := testEval(t, input)
+	fn, ok := evaluated.(*object.Function)
+	if !ok {
+		t.Fatalf("object is not Function. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if len(fn.Parameters) != 1 {
+		t.Fatalf("function has wrong parameters. Parameters=%+v", fn.Parameters)
+	}
+
+	if fn.Parameters[0].String() != "x" {
+		t.Fatalf("parameter is not 'x'. got=%q", fn.Parameters[0])
+	}
+
+	if fn.Body.String() != "{ (x + 2) }" {
+		t.Fatalf("body is not %q. got=%q", "{ (x + 2) }", fn.Body.String())
+	}
+
+	if fn.Inspect() != "fn(x) { (x + 2) }" {
+		t.Errorf("fn.Inspect() wrong. got=%q", fn.Inspect())
+	}
+}
+
+func TestStringLiteral(t *testing.T) {
+	evaluated := testEval(t, `"Hello\tWorld!"`)
+
+	str, ok := evaluated.(*object.String)
+	if !ok {
+		t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if str.Value != "Hello\tWorld!" {
+		t.Errorf("String has wrong value. got=%q", str.Value)
+	}
+}
+
+func TestStringConcatenation(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"Hello" + " " + "World!"`, "Hello World!"},
+		{`let greet = fn(name) { "Hello, " + name + "\n" }; greet("Monkey")`, "Hello, Monkey\n"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		str, ok := evaluated.(*object.String)
+		if !ok {
+			t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
+		}
+
+		if str.Value != tt.expected {
+			t.Errorf("String has wrong value. expected=%q, got=%q", tt.expected, str.Value)
+		}
+	}
+}
+
+func TestStringComparison(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{`"a" == "a"`, true},
+		{`"a" == "b"`, false},
+		{`"a" != "b"`, true},
+		{`"a" + "b" == "ab"`, true},
+	}
+
+	for _, tt := range tests {
+		testBoolObject(t, testEval(t, tt.input), tt.expected)
+	}
+}
+
+func TestLetStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let a = 5; a;", 5},
+		{"let a = 5 * 5; a;", 25},
+		{"let a = 5; let b = a; b;", 5},
+		{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(t, tt.input), tt.expected)
+	}
+}
+
+func TestFunctionApplication(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let identity = fn(x) { x; }; identity(5);", 5},
+		{"let identity = fn(x) { return x; }; identity(5);", 5},
+		{"let double = fn(x) { x * 2; }; double(5);", 10},
+		{"let add = fn(x, y) { x + y; }; add(5, 5);", 10},
+		{"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));", 20},
+		{"fn(x) { x; }(5)", 5},
+		{"let fact = fn(n) { if (n < 2) { return 1; } n * fact(n - 1) }; fact(5)", 120},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(t, tt.input), tt.expected)
+	}
+}
+
+func TestClosures(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{`
+let newadder = fn(x) {
+	fn(y) { x + y };
+};
+
+let addtwo = newadder(2);
+addtwo(2);`, 4},
+		{`
+let counter = fn(start) {
+	fn(step) { start + step }
+};
+
+let fromten = counter(10);
+let fromtwenty = counter(20);
+fromten(1) + fromtwenty(2);`, 33},
+		{`
+let curry = fn(f) { fn(a) { fn(b) { f(a, b) } } };
+let mul = fn(a, b) { a * b };
+curry(mul)(6)(7);`, 42},
+		{`
+let x = 1;
+let shadow = fn(x) { let x = x * 10; x };
+shadow(5) + x;`, 51},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(t, tt.input), tt.expected)
+	}
+}
+
+func TestStackOverflow(t *testing.T) {
+	tests := []string{
+		"let f = fn(n) { f(n + 1) }; f(0)",
+		"let f = fn(n) { 1 + f(n + 1) }; f(0)",
+	}
+
+	for _, input := range tests {
+		errobj, ok := testEval(t, input).(*object.Error)
+		if !ok {
+			t.Fatalf("%q - no error returned", input)
+		}
+
+		if errobj.Message != "stack overflow" {
+			t.Errorf("%q - wrong error. got=%q", input, errobj.Message)
+		}
+	}
+}
+
+func TestDeepRecursion(t *testing.T) {
+	input := "let count = fn(n) { if (n == 0) { 0 } else { 1 + count(n - 1) } }; count(5000)"
+	testIntegerObject(t, testEval(t, input), 5000)
+}
+
+func TestArrayLiterals(t *testing.T) {
+	evaluated := testEval(t, "[1, 2 * 2, 3 + 3]")
+
+	result, ok := evaluated.(*object.Array)
+	if !ok {
+		t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if len(result.Elements) != 3 {
+		t.Fatalf("array has wrong number of elements. got=%d", len(result.Elements))
+	}
+
+	testIntegerObject(t, result.Elements[0], 1)
+	testIntegerObject(t, result.Elements[1], 4)
+	testIntegerObject(t, result.Elements[2], 6)
+}
+
+func TestArrayIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"[1, 2, 3][0]", 1},
+		{"[1, 2, 3][1]", 2},
+		{"[1, 2, 3][2]", 3},
+		{"let i = 0; [1][i];", 1},
+		{"[1, 2, 3][1 + 1];", 3},
+		{"let myArray = [1, 2, 3]; myArray[2];", 3},
+		{"let myArray = [1, 2, 3]; myArray[0] + myArray[1] + myArray[2];", 6},
+		{"let myArray = [1, 2, 3]; let i = myArray[0]; myArray[i]", 2},
+		{"[1, 2, 3][-1]", 3},
+		{"[1, 2, 3][-3]", 1},
+		{"[[1, 2], [3, 4]][1][0]", 3},
+		{"let f = fn() { [5, 6] }; f()[1]", 6},
+		{"[fn(x) { x * 2 }][0](21)", 42},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(t, tt.input), tt.expected)
+	}
+}
+
+func TestArraySliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[1, 2, 3, 4][1:3]", "[2, 3]"},
+		{"[1, 2, 3, 4][:2]", "[1, 2]"},
+		{"[1, 2, 3, 4][2:]", "[3, 4]"},
+		{"[1, 2, 3, 4][:]", "[1, 2, 3, 4]"},
+		{"[1, 2, 3, 4][-2:]", "[3, 4]"},
+		{"[1, 2, 3, 4][:-1]", "[1, 2, 3]"},
+		{"[1, 2, 3, 4][2:2]", "[]"},
+		{"[1, 2, 3, 4][4:]", "[]"},
+		{"let a = [1, 2, 3]; a[1:][0]", "2"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%q wrong. expected=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestSliceCopiesElements(t *testing.T) {
+	env := object.NewEnvironment()
+	Eval(parser.NewParser("let a = [1, 2, 3]; let b = a[0:2];").Parse(), env)
+
+	a, _ := env.Get("a")
+	b, _ := env.Get("b")
+
+	b.(*object.Array).Elements[0] = &object.Integer{Value: 9}
+
+	if a.Inspect() != "[1, 2, 3]" {
+		t.Errorf("slice shares elements with its source. a=%s", a.Inspect())
+	}
+}
+
+func TestHashLiterals(t *testing.T) {
+	input := `let two = "two";
+	{
+		"one": 10 - 9,
+		two: 1 + 1,
+		"thr" + "ee": 6 / 2,
+		4: 4,
+		true: 5,
+		false: 6,
+		100000000000000000000: 7
+	}`
+
+	evaluated := testEval(t, input)
+	result, ok := evaluated.(*object.Hash)
+	if !ok {
+		t.Fatalf("Eval didn't return Hash. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	big, _ := new(big.Int).SetString("100000000000000000000", 10)
+	expected := map[object.HashKey]int64{
+		(&object.String{Value: "one"}).HashKey():   1,
+		(&object.String{Value: "two"}).HashKey():   2,
+		(&object.String{Value: "three"}).HashKey(): 3,
+		(&object.Integer{Value: 4}).HashKey():      4,
+		TRUE.HashKey():                             5,
+		FALSE.HashKey():                            6,
+		(&object.BigInteger{Value: big}).HashKey(): 7,
+	}
+
+	if len(result.Pairs) != len(expected) {
+		t.Fatalf("Hash has wrong number of pairs. got=%d", len(result.Pairs))
+	}
+
+	for key, value := range expected {
+		pair, ok := result.Pairs[key]
+		if !ok {
+			t.Errorf("no pair for given key in Pairs")
+			continue
+		}
+		testIntegerObject(t, pair.Value, value)
+	}
+}
+
+func TestHashIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`{"foo": 5}["foo"]`, 5},
+		{`{"foo": 5}["bar"]`, nil},
+		{`let key = "foo"; {"foo": 5}[key]`, 5},
+		{`{}["foo"]`, nil},
+		{`{5: 5}[5]`, 5},
+		{`{true: 5}[true]`, 5},
+		{`{false: 5}[false]`, 5},
+		{`{"a": 1, "a": 2}["a"]`, 2},
+		{`{9223372036854775807 + 1: 5}[9223372036854775808]`, 5},
+		{`{1: 5}[(9223372036854775807 + 1) - 9223372036854775807]`, 5},
+		{`{"xs": [1, 2, 3]}["xs"][1]`, 2},
+		{`let h = {"f": fn(x) { x * 2 }}; h["f"](2)`, 4},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if integer, ok := tt.expected.(int); ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestHashInspect(t *testing.T) {
+	evaluated := testEval(t, `{"b": 2, "a": [1], true: "x"}`)
+
+	expected := "{a: [1], b: 2, true: x}"
+	if evaluated.Inspect() != expected {
+		t.Errorf("Inspect() wrong. expected=%q, got=%q", expected, evaluated.Inspect())
+	}
+}
+
+func TestBuiltinFunctions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`len("")`, 0},
+		{`len("four")`, 4},
+		{`len("héllo")`, 5},
+		{`len([1, 2, 3])`, 3},
+		{`len({"a": 1})`, 1},
+		{`len(1)`, "argument to `len` not supported, got INTEGER"},
+		{`len("one", "two")`, "wrong number of arguments to `len`: want=1, got=2"},
+		{`first([1, 2, 3])`, 1},
+		{`first([])`, nil},
+		{`first(1)`, "argument to `first` must be ARRAY, got INTEGER"},
+		{`rest([1, 2, 3])`, "[2, 3]"},
+		{`rest([])`, nil},
+		{`push([], 1)`, "[1]"},
+		{`push(1, 1)`, "argument to `push` must be ARRAY, got INTEGER"},
+		{`push([1])`, "wrong number of arguments to `push`: want=2, got=1"},
+		{`let a = [1]; push(a, 2); a`, "[1]"},
+		{`type(1)`, "INTEGER"},
+		{`type("a")`, "STRING"},
+		{`type(len)`, "BUILTIN"},
+		{`type(fn() {})`, "FUNCTION"},
+		{`let len = fn(x) { 42 }; len("a")`, 42},
+		{`let f = fn(xs) { len(xs) }; f([1, 2])`, 2},
+		{`if (first([])) { 1 } else { 2 }`, 2},
+		{`first([]) == first([])`, "true"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			if errobj, ok := evaluated.(*object.Error); ok {
+				if errobj.Message != expected {
+					t.Errorf("wrong error message. expected=%q, got=%q", expected, errobj.Message)
+				}
+				continue
+			}
+
+			if evaluated.Inspect() != expected {
+				t.Errorf("%q wrong. expected=%s, got=%s", tt.input, expected, evaluated.Inspect())
+			}
+		}
+	}
+}
+
+func TestBuiltinErrorPosition(t *testing.T) {
+	errobj, ok := testEval(t, "let x = 1;\nlen(x)").(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned")
+	}
+
+	if errobj.Pos.String() != "2:1" || errobj.End.String() != "2:7" {
+		t.Errorf("wrong error position. got=%s-%s", errobj.Pos, errobj.End)
+	}
+}
+
+func TestHostBuiltins(t *testing.T) {
+	builtins := object.NewBuiltins()
+	builtins.Register("double", 1, func(args ...object.Object) object.Object {
+		integer, ok := args[0].(*object.Integer)
+		if !ok {
+			return object.NewError("argument to `double` must be INTEGER, got %s", args[0].Type())
+		}
+		return &object.Integer{Value: integer.Value * 2}
+	})
+	builtins.Register("no", 0, func(args ...object.Object) object.Object {
+		return &object.Boolean{Value: false}
+	})
+
+	env := object.NewEnvironment()
+	env.SetBuiltins(builtins)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"double(21)", "42"},
+		{"let f = fn(x) { double(x) }; f(2)", "4"},
+		{`double("a")`, "ERROR: argument to `double` must be INTEGER, got STRING"},
+		{"if (no()) { 1 } else { 2 }", "2"},
+		{"no() == false", "true"},
+		{"len([])", "ERROR: identifier not found: len"},
+	}
+
+	for _, tt := range tests {
+		evaluated := Eval(parser.NewParser(tt.input).Parse(), env)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%q wrong. expected=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func testEval(t *testing.T, input string) object.Object {
+	p := parser.NewParser(input)
+	prog := p.Parse()
+
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %q", input, p.Errors())
+	}
+
+	env := object.NewEnvironment()
+	return Eval(prog, env)
+}
+
+func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
+	result, ok := obj.(*object.Integer)
+	if !ok {
+		t.Errorf("object is not Integer. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%d, want=%d", result.Value, expected)
+		return false
+	}
+	return true
+}
+
+func testBoolObject(t *testing.T, obj object.Object, expected bool) bool {
+	result, ok := obj.(*object.Boolean)
+	if !ok {
+		t.Errorf("object is not Boolean. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%t, want=%t", result.Value, expected)
+		return false
+	}
+	return true
+}
+
+func testNullObject(t *testing.T, obj object.Object) bool {
+	if obj != NULL {
+		t.Errorf("object is not NULL. got=%T (%+v)", obj, obj)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,47 @@
+package evaluator
+
+import (
+	"github.com/hellozee/monkey/lib/object"
+	"github.com/hellozee/monkey/lib/parser"
+)
+
+// evalhashliteral evaluates the pairs in source order, a later pair with
+// an equal key replaces an earlier one.
+func evalhashliteral(node *parser.HashLiteral, env *object.Environment) object.Object {
+	pairs := make(map[object.HashKey]object.HashPair)
+
+	for _, pair := range node.Pairs {
+		key := Eval(pair.Key, env)
+		if isabrupt(key) {
+			return key
+		}
+
+		hashkey, err := HashKey(key)
+		if err != nil {
+			return locate(err, pair.Key)
+		}
+
+		value := Eval(pair.Value, env)
+		if isabrupt(value) {
+			return value
+		}
+
+		pairs[hashkey] = object.HashPair{Key: key, Value: value}
+	}
+
+	return &object.Hash{Pairs: pairs}
+}
+
+func evalhashindex(hash *object.Hash, index object.Object) object.Object {
+	key, err := HashKey(index)
+	if err != nil {
+		return err
+	}
+
+	pair, ok := hash.Pairs[key]
+	if !ok {
+		return NULL
+	}
+
+	return pair.Value
+}
@@ -0,0 +1,114 @@
+package evaluator
+
+import (
+	"math"
+	"math/big"
+
+	"github.com/hellozee/monkey/lib/object"
+)
+
+// evalintegerinfixexpr handles operators on two integers. Integers live
+// in an int64 while they fit and move to a big.Int when an operation
+// overflows, results that fit again are demoted back to an int64.
+func evalintegerinfixexpr(operator string, left, right object.Object) object.Object {
+	l, lok := left.(*object.Integer)
+	r, rok := right.(*object.Integer)
+
+	if lok && rok {
+		if result, ok := evalsmallinfixexpr(operator, l.Value, r.Value); ok {
+			return result
+		}
+	}
+
+	return evalbiginfixexpr(operator, tobig(left), tobig(right))
+}
+
+// evalsmallinfixexpr evaluates operator on int64 operands, it returns
+// false when the result does not fit in an int64.
+func evalsmallinfixexpr(operator string, lval, rval int64) (object.Object, bool) {
+	switch operator {
+	case "+":
+		sum := lval + rval
+		if (lval > 0 && rval > 0 && sum < 0) || (lval < 0 && rval < 0 && sum >= 0) {
+			return nil, false
+		}
+		return &object.Integer{Value: sum}, true
+	case "-":
+		diff := lval - rval
+		if (lval >= 0 && rval < 0 && diff < 0) || (lval < 0 && rval > 0 && diff >= 0) {
+			return nil, false
+		}
+		return &object.Integer{Value: diff}, true
+	case "*":
+		if lval == 0 || rval == 0 {
+			return &object.Integer{Value: 0}, true
+		}
+		product := lval * rval
+		if product/rval != lval || (lval == -1 && rval == math.MinInt64) || (rval == -1 && lval == math.MinInt64) {
+			return nil, false
+		}
+		return &object.Integer{Value: product}, true
+	case "/":
+		if rval == 0 {
+			return newerror("division by zero"), true
+		}
+		if lval == math.MinInt64 && rval == -1 {
+			return nil, false
+		}
+		return &object.Integer{Value: lval / rval}, true
+	case "<":
+		return nativebool(lval < rval), true
+	case ">":
+		return nativebool(lval > rval), true
+	case "==":
+		return nativebool(lval == rval), true
+	case "!=":
+		return nativebool(lval != rval), true
+	default:
+		return newerror("unknown operator: %s %s %s", object.INTEGER, operator, object.INTEGER), true
+	}
+}
+
+func evalbiginfixexpr(operator string, lval, rval *big.Int) object.Object {
+	switch operator {
+	case "+":
+		return normalize(new(big.Int).Add(lval, rval))
+	case "-":
+		return normalize(new(big.Int).Sub(lval, rval))
+	case "*":
+		return normalize(new(big.Int).Mul(lval, rval))
+	case "/":
+		if rval.Sign() == 0 {
+			return newerror("division by zero")
+		}
+		return normalize(new(big.Int).Quo(lval, rval))
+	case "<":
+		return nativebool(lval.Cmp(rval) < 0)
+	case ">":
+		return nativebool(lval.Cmp(rval) > 0)
+	case "==":
+		return nativebool(lval.Cmp(rval) == 0)
+	case "!=":
+		return nativebool(lval.Cmp(rval) != 0)
+	default:
+		return newerror("unknown operator: %s %s %s", object.INTEGER, operator, object.INTEGER)
+	}
+}
+
+// normalize demotes value to a plain Integer when it fits in an int64.
+func normalize(value *big.Int) object.Object {
+	if value.IsInt64() {
+		return &object.Integer{Value: value.Int64()}
+	}
+	return &object.BigInteger{Value: value}
+}
+
+func tobig(obj object.Object) *big.Int {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return big.NewInt(obj.Value)
+	case *object.BigInteger:
+		return obj.Value
+	}
+	return new(big.Int)
+}
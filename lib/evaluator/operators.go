@@ -0,0 +1,44 @@
+package evaluator
+
+import (
+	"github.com/hellozee/monkey/lib/object"
+)
+
+// The functions below expose the semantics of the evaluator's operators
+// so other backends, like the vm, give the same results. Errors are
+// returned as *object.Error without a position.
+
+func Prefix(operator string, right object.Object) object.Object {
+	return evalprefixexpr(operator, right)
+}
+
+func Infix(operator string, left, right object.Object) object.Object {
+	return evalinfixexpr(operator, left, right)
+}
+
+func Index(left, index object.Object) object.Object {
+	return evalindexexpr(left, index)
+}
+
+// Slice takes nil for a bound that was left out.
+func Slice(left, low, high object.Object) object.Object {
+	return evalsliceexpr(left, low, high)
+}
+
+// HashKey returns the key obj is stored under in a hash, or an error when
+// obj can't be used as a key.
+func HashKey(obj object.Object) (object.HashKey, *object.Error) {
+	hashable, ok := obj.(object.Hashable)
+	if !ok {
+		return object.HashKey{}, newerror("unusable as hash key: %s", obj.Type())
+	}
+	return hashable.HashKey(), nil
+}
+
+func Truthy(obj object.Object) bool {
+	return istruthy(obj)
+}
+
+func Bool(value bool) *object.Boolean {
+	return nativebool(value)
+}
@@ -0,0 +1,692 @@
+// Package mkc reads and writes compiled Monkey programs.
+//
+// A .mkc file is laid out as:
+//
+//	magic      "\x7fMKC"
+//	version    uint16, big endian
+//	source     name of the script the file was built from
+//	text       the script itself, quoted in runtime errors
+//	constants  count, then a tag byte and the value of each constant,
+//	           functions are stored as an index into the function table
+//	globals    count, then the name of every global slot
+//	functions  count, then every function, the first one is the main
+//	           program: parameters, locals, source and instructions
+//	lines      for every function, its table of source positions
+//	checksum   CRC-32 (IEEE) of everything before it, big endian
+//
+// Counts and integers are varints, strings and byte slices are prefixed
+// with their length.
+package mkc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"math/big"
+
+	"github.com/hellozee/monkey/lib/code"
+	"github.com/hellozee/monkey/lib/compiler"
+	"github.com/hellozee/monkey/lib/object"
+	"github.com/hellozee/monkey/lib/parser"
+)
+
+const Magic = "\x7fMKC"
+
+// Version is the format written by this package, it has to change
+// whenever the layout or the instruction set does.
+const Version = 3
+
+// Tags of the constants.
+const (
+	tagInteger byte = iota + 1
+	tagBigInteger
+	tagFloat
+	tagString
+	tagFunction
+)
+
+var (
+	ErrNotCompiled = errors.New("not a compiled monkey program")
+	ErrChecksum    = errors.New("checksum mismatch, the file is corrupt")
+	ErrTruncated   = errors.New("unexpected end of file")
+)
+
+// VersionError is returned for files in a format this package can't read.
+type VersionError struct {
+	Version int
+}
+
+func (e *VersionError) Error() string {
+	return fmt.Sprintf("compiled with format version %d, this monkey reads version %d; rebuild it with `monkey build`", e.Version, Version)
+}
+
+// File is a compiled program and the name and text of the script it
+// came from.
+type File struct {
+	Source   string
+	Text     string
+	Bytecode *compiler.Bytecode
+}
+
+// IsCompiled reports whether data starts like a .mkc file.
+func IsCompiled(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(Magic))
+}
+
+func Write(w io.Writer, f *File) error {
+	e := &encoder{}
+	e.buf.WriteString(Magic)
+	e.uint16(Version)
+	e.string(f.Source)
+	e.string(f.Text)
+
+	functions := []*object.CompiledFunction{f.Bytecode.Main}
+
+	e.uvarint(len(f.Bytecode.Constants))
+	for _, constant := range f.Bytecode.Constants {
+		switch constant := constant.(type) {
+		case *object.Integer:
+			e.buf.WriteByte(tagInteger)
+			e.varint(constant.Value)
+		case *object.BigInteger:
+			e.buf.WriteByte(tagBigInteger)
+			e.string(constant.Value.String())
+		case *object.Float:
+			e.buf.WriteByte(tagFloat)
+			e.uint64(math.Float64bits(constant.Value))
+		case *object.String:
+			e.buf.WriteByte(tagString)
+			e.string(constant.Value)
+		case *object.CompiledFunction:
+			e.buf.WriteByte(tagFunction)
+			e.uvarint(len(functions))
+			functions = append(functions, constant)
+		default:
+			return fmt.Errorf("cannot store a constant of type %s", constant.Type())
+		}
+	}
+
+	e.strings(f.Bytecode.Globals)
+
+	e.uvarint(len(functions))
+	for _, fn := range functions {
+		e.uvarint(fn.NumParameters)
+		e.strings(fn.Locals)
+		e.string(fn.Source)
+		e.bytes(fn.Instructions)
+	}
+
+	for _, fn := range functions {
+		e.uvarint(len(fn.Positions))
+		for _, pos := range fn.Positions {
+			e.uvarint(pos.Offset)
+			e.position(pos.Pos)
+			e.position(pos.End)
+		}
+	}
+
+	e.uint32(crc32.ChecksumIEEE(e.buf.Bytes()))
+
+	_, err := w.Write(e.buf.Bytes())
+	return err
+}
+
+func Read(r io.Reader) (*File, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if !IsCompiled(data) {
+		return nil, ErrNotCompiled
+	}
+
+	d := &decoder{data: data[len(Magic):]}
+	if version := d.uint16(); d.err != nil {
+		return nil, d.err
+	} else if version != Version {
+		return nil, &VersionError{Version: int(version)}
+	}
+
+	// Only check the sum once the version is known, an older format may
+	// not end in one.
+	if len(data) < len(Magic)+2+4 {
+		return nil, ErrTruncated
+	}
+	body, sum := data[:len(data)-4], data[len(data)-4:]
+	if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(sum) {
+		return nil, ErrChecksum
+	}
+	d.data = d.data[:len(d.data)-4]
+
+	f := &File{Source: d.string(), Text: d.string(), Bytecode: &compiler.Bytecode{}}
+
+	// Functions are stored after the constants that refer to them.
+	type reference struct{ constant, function int }
+	var references []reference
+
+	constants := make([]object.Object, d.count())
+	for i := range constants {
+		switch tag := d.byte(); tag {
+		case tagInteger:
+			constants[i] = &object.Integer{Value: d.varint()}
+		case tagBigInteger:
+			value, ok := new(big.Int).SetString(d.string(), 10)
+			if !ok {
+				d.fail(fmt.Errorf("constant %d is not an integer", i))
+			}
+			constants[i] = &object.BigInteger{Value: value}
+		case tagFloat:
+			constants[i] = &object.Float{Value: math.Float64frombits(d.uint64())}
+		case tagString:
+			constants[i] = &object.String{Value: d.string()}
+		case tagFunction:
+			references = append(references, reference{i, d.uvarint()})
+		default:
+			d.fail(fmt.Errorf("constant %d has an unknown tag %d", i, tag))
+		}
+	}
+
+	f.Bytecode.Globals = d.strings()
+
+	functions := make([]*object.CompiledFunction, d.count())
+	for i := range functions {
+		functions[i] = &object.CompiledFunction{
+			NumParameters: d.uvarint(),
+			Locals:        d.strings(),
+			Source:        d.string(),
+			Instructions:  d.bytes(),
+		}
+	}
+
+	for _, fn := range functions {
+		n := d.count()
+		if n == 0 {
+			continue
+		}
+
+		fn.Positions = make(code.Positions, n)
+		for i := range fn.Positions {
+			fn.Positions[i] = code.Position{Offset: d.uvarint(), Pos: d.position(), End: d.position()}
+		}
+	}
+
+	if d.err == nil && len(d.data) != 0 {
+		d.fail(fmt.Errorf("%d bytes of trailing data", len(d.data)))
+	}
+	if d.err == nil && len(functions) == 0 {
+		d.fail(errors.New("missing the main program"))
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	for _, ref := range references {
+		if ref.function < 1 || ref.function >= len(functions) {
+			return nil, fmt.Errorf("constant %d refers to a missing function %d", ref.constant, ref.function)
+		}
+		constants[ref.constant] = functions[ref.function]
+	}
+
+	f.Bytecode.Main = functions[0]
+	f.Bytecode.Constants = constants
+
+	if err := verify(functions, f.Bytecode); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// instruction is a decoded instruction of a function being verified.
+type instruction struct {
+	offset   int
+	op       code.Opcode
+	def      *code.Definition
+	operands []int
+}
+
+// verify checks that the functions can run on the VM without breaking
+// it: instructions decode, operands refer to constants, globals and
+// locals that exist, jumps land on instructions, nothing pops more than
+// was pushed, closures only read the scopes they are created in and
+// positions can be shown in errors. It
+// catches files that have a valid checksum but weren't written by Write.
+func verify(functions []*object.CompiledFunction, bytecode *compiler.Bytecode) error {
+	decoded := make([][]instruction, len(functions))
+
+	for i, fn := range functions {
+		list, err := decode(fn)
+		if err == nil {
+			err = checkoperands(fn, list, bytecode)
+		}
+		if err == nil {
+			err = checkstack(fn, list, fn == bytecode.Main)
+		}
+		if err == nil {
+			err = checkpositions(fn)
+		}
+		if err != nil {
+			return fmt.Errorf("function %d: %w", i, err)
+		}
+		decoded[i] = list
+	}
+
+	return checkscopes(functions, decoded, bytecode)
+}
+
+func decode(fn *object.CompiledFunction) ([]instruction, error) {
+	var list []instruction
+
+	ins := fn.Instructions
+	for offset := 0; offset < len(ins); {
+		def, err := code.Lookup(ins[offset])
+		if err != nil {
+			return nil, fmt.Errorf("%04d: %w", offset, err)
+		}
+
+		width := 0
+		for _, w := range def.OperandWidths {
+			width += w
+		}
+		if offset+1+width > len(ins) {
+			return nil, fmt.Errorf("%04d: %s is truncated", offset, def.Name)
+		}
+
+		operands, read := code.ReadOperands(def, ins[offset+1:])
+		list = append(list, instruction{offset: offset, op: code.Opcode(ins[offset]), def: def, operands: operands})
+		offset += 1 + read
+	}
+
+	return list, nil
+}
+
+// checkoperands checks the operands of every instruction on their own,
+// the depth of OpGetOuter is checked by checkscopes.
+func checkoperands(fn *object.CompiledFunction, list []instruction, bytecode *compiler.Bytecode) error {
+	main := fn == bytecode.Main
+
+	// The main program runs without a scope of its own.
+	if main && len(fn.Locals) != 0 {
+		return errors.New("the main program can't have locals")
+	}
+	if fn.NumParameters > len(fn.Locals) {
+		return fmt.Errorf("%d parameters but only %d locals", fn.NumParameters, len(fn.Locals))
+	}
+
+	starts := make(map[int]bool, len(list)+1)
+	for _, ins := range list {
+		starts[ins.offset] = true
+	}
+	starts[len(fn.Instructions)] = true
+
+	for _, ins := range list {
+		var ok bool
+		switch ins.op {
+		case code.OpConstant:
+			ok = ins.operands[0] < len(bytecode.Constants)
+		case code.OpClosure:
+			_, ok = closure(ins, bytecode)
+		case code.OpGetGlobal, code.OpSetGlobal:
+			ok = ins.operands[0] < len(bytecode.Globals)
+		case code.OpGetLocal, code.OpSetLocal:
+			ok = ins.operands[0] < len(fn.Locals)
+		case code.OpGetOuter:
+			ok = !main && ins.operands[0] > 0
+		case code.OpInfixLocalConstant:
+			_, infix := code.InfixOperators[code.Opcode(ins.operands[2])]
+			ok = infix && ins.operands[0] < len(fn.Locals) && ins.operands[1] < len(bytecode.Constants)
+		case code.OpJump, code.OpJumpNotTruthy:
+			ok = starts[ins.operands[0]]
+		default:
+			ok = true
+		}
+		if !ok {
+			return fmt.Errorf("%04d: %s has an invalid operand", ins.offset, code.FormatInstruction(ins.def, ins.operands))
+		}
+	}
+
+	return nil
+}
+
+// closure returns the function an OpClosure creates.
+func closure(ins instruction, bytecode *compiler.Bytecode) (*object.CompiledFunction, bool) {
+	if ins.operands[0] >= len(bytecode.Constants) {
+		return nil, false
+	}
+	fn, ok := bytecode.Constants[ins.operands[0]].(*object.CompiledFunction)
+	return fn, ok
+}
+
+// checkstack follows every path through the instructions and checks
+// that no instruction pops more values than the function pushed and that
+// paths meet with the same number of values. Only the main program may run
+// past its last instruction, functions have to return.
+func checkstack(fn *object.CompiledFunction, list []instruction, main bool) error {
+	index := make(map[int]int, len(list)+1)
+	for i, ins := range list {
+		index[ins.offset] = i
+	}
+	index[len(fn.Instructions)] = len(list)
+
+	depths := make([]int, len(list)+1)
+	for i := range depths {
+		depths[i] = -1
+	}
+	depths[0] = 0
+	work := []int{0}
+
+	reach := func(from, to, depth int) error {
+		switch {
+		case depths[to] == -1:
+			depths[to] = depth
+			work = append(work, to)
+		case depths[to] != depth:
+			return fmt.Errorf("%04d: %s leaves the stack %d deep, another path leaves it %d deep", list[from].offset, list[from].def.Name, depth, depths[to])
+		}
+		return nil
+	}
+
+	for len(work) != 0 {
+		i := work[len(work)-1]
+		work = work[:len(work)-1]
+
+		if i == len(list) {
+			if !main {
+				return errors.New("runs past its last instruction")
+			}
+			continue
+		}
+
+		ins := list[i]
+		pops, pushes := effect(ins)
+		if depths[i] < pops {
+			return fmt.Errorf("%04d: %s with the stack %d deep", ins.offset, ins.def.Name, depths[i])
+		}
+		depth := depths[i] - pops + pushes
+
+		switch ins.op {
+		case code.OpReturnValue, code.OpReturn:
+			continue
+		case code.OpJump:
+			if err := reach(i, index[ins.operands[0]], depth); err != nil {
+				return err
+			}
+			continue
+		case code.OpJumpNotTruthy:
+			if err := reach(i, index[ins.operands[0]], depth); err != nil {
+				return err
+			}
+		}
+
+		if err := reach(i, i+1, depth); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// effect returns how many values ins pops and then pushes.
+func effect(ins instruction) (int, int) {
+	switch ins.op {
+	case code.OpConstant, code.OpTrue, code.OpFalse, code.OpNull, code.OpClosure,
+		code.OpGetGlobal, code.OpGetLocal, code.OpGetOuter, code.OpInfixLocalConstant:
+		return 0, 1
+	case code.OpPop, code.OpJumpNotTruthy, code.OpSetGlobal, code.OpSetLocal, code.OpReturnValue:
+		return 1, 0
+	case code.OpMinus, code.OpBang, code.OpHashKey:
+		return 1, 1
+	case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpIndex,
+		code.OpEqual, code.OpNotEqual, code.OpGreaterThan, code.OpLessThan:
+		return 2, 1
+	case code.OpArray:
+		return ins.operands[0], 1
+	case code.OpHash:
+		return 2 * ins.operands[0], 1
+	case code.OpSlice:
+		pops := 1
+		if ins.operands[0]&code.SliceLow != 0 {
+			pops++
+		}
+		if ins.operands[0]&code.SliceHigh != 0 {
+			pops++
+		}
+		return pops, 1
+	case code.OpCall:
+		return ins.operands[0] + 1, 1
+	}
+	return 0, 0
+}
+
+// checkpositions checks that the spans of errors can be shown: lines
+// and columns start at 1, and spans don't end before they start.
+func checkpositions(fn *object.CompiledFunction) error {
+	for _, pos := range fn.Positions {
+		switch {
+		case pos.Pos.Line > 0 && pos.Pos.Column == 0, pos.End.Line > 0 && pos.End.Column == 0:
+			return fmt.Errorf("%04d: position %s-%s has no column", pos.Offset, pos.Pos, pos.End)
+		case pos.End.Line < pos.Pos.Line, pos.End.Line == pos.Pos.Line && pos.End.Column < pos.Pos.Column:
+			return fmt.Errorf("%04d: position %s-%s ends before it starts", pos.Offset, pos.Pos, pos.End)
+		}
+	}
+	return nil
+}
+
+// checkscopes checks OpGetOuter against the functions a closure can be
+// created in: every scope depth levels out has to exist and have the
+// local. The scope one level out is the one of the function running the
+// OpClosure, the main program has none.
+func checkscopes(functions []*object.CompiledFunction, decoded [][]instruction, bytecode *compiler.Bytecode) error {
+	index := make(map[*object.CompiledFunction]int, len(functions))
+	for i, fn := range functions {
+		index[fn] = i
+	}
+
+	parents := make([]map[int]bool, len(functions))
+	for i := range parents {
+		parents[i] = make(map[int]bool)
+	}
+	for i, list := range decoded {
+		for _, ins := range list {
+			if ins.op == code.OpClosure {
+				fn, _ := closure(ins, bytecode)
+				parents[index[fn]][i] = true
+			}
+		}
+	}
+
+	for i, list := range decoded {
+		for _, ins := range list {
+			if ins.op != code.OpGetOuter {
+				continue
+			}
+
+			level := map[int]bool{i: true}
+			for depth := 0; depth < ins.operands[0]; depth++ {
+				outer := make(map[int]bool)
+				for fn := range level {
+					for parent := range parents[fn] {
+						outer[parent] = true
+					}
+				}
+				if outer[0] {
+					return fmt.Errorf("function %d: %04d: %s reads past the outermost function", i, ins.offset, code.FormatInstruction(ins.def, ins.operands))
+				}
+				level = outer
+			}
+
+			for fn := range level {
+				if ins.operands[1] >= len(functions[fn].Locals) {
+					return fmt.Errorf("function %d: %04d: %s reads a local function %d doesn't have", i, ins.offset, code.FormatInstruction(ins.def, ins.operands), fn)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+type encoder struct {
+	buf bytes.Buffer
+}
+
+func (e *encoder) uvarint(n int) {
+	e.buf.Write(binary.AppendUvarint(nil, uint64(n)))
+}
+
+func (e *encoder) varint(n int64) {
+	e.buf.Write(binary.AppendVarint(nil, n))
+}
+
+func (e *encoder) uint16(n uint16) {
+	e.buf.Write(binary.BigEndian.AppendUint16(nil, n))
+}
+
+func (e *encoder) uint32(n uint32) {
+	e.buf.Write(binary.BigEndian.AppendUint32(nil, n))
+}
+
+func (e *encoder) uint64(n uint64) {
+	e.buf.Write(binary.BigEndian.AppendUint64(nil, n))
+}
+
+func (e *encoder) bytes(b []byte) {
+	e.uvarint(len(b))
+	e.buf.Write(b)
+}
+
+func (e *encoder) string(s string) {
+	e.bytes([]byte(s))
+}
+
+func (e *encoder) strings(s []string) {
+	e.uvarint(len(s))
+	for _, str := range s {
+		e.string(str)
+	}
+}
+
+func (e *encoder) position(pos parser.Position) {
+	e.uvarint(pos.Line)
+	e.uvarint(pos.Column)
+	e.uvarint(pos.Offset)
+}
+
+// decoder reads from data, after the first error every read returns a
+// zero value and err keeps that error.
+type decoder struct {
+	data []byte
+	err  error
+}
+
+func (d *decoder) fail(err error) {
+	if d.err == nil {
+		d.err = err
+	}
+	d.data = nil
+}
+
+func (d *decoder) take(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n > len(d.data) {
+		d.fail(ErrTruncated)
+		return nil
+	}
+	b := d.data[:n]
+	d.data = d.data[n:]
+	return b
+}
+
+func (d *decoder) byte() byte {
+	if b := d.take(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (d *decoder) uint16() uint16 {
+	if b := d.take(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (d *decoder) uint64() uint64 {
+	if b := d.take(8); b != nil {
+		return binary.BigEndian.Uint64(b)
+	}
+	return 0
+}
+
+func (d *decoder) uvarint() int {
+	if d.err != nil {
+		return 0
+	}
+	n, read := binary.Uvarint(d.data)
+	if read <= 0 || n > math.MaxInt32 {
+		d.fail(ErrTruncated)
+		return 0
+	}
+	d.data = d.data[read:]
+	return int(n)
+}
+
+// count reads the length of something stored next, it can't be larger
+// than what is left so a corrupt count doesn't allocate much.
+func (d *decoder) count() int {
+	n := d.uvarint()
+	if n > len(d.data) {
+		d.fail(ErrTruncated)
+		return 0
+	}
+	return n
+}
+
+func (d *decoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	n, read := binary.Varint(d.data)
+	if read <= 0 {
+		d.fail(ErrTruncated)
+		return 0
+	}
+	d.data = d.data[read:]
+	return n
+}
+
+func (d *decoder) bytes() []byte {
+	n := d.count()
+	if n == 0 {
+		return nil
+	}
+	return append([]byte{}, d.take(n)...)
+}
+
+func (d *decoder) string() string {
+	return string(d.take(d.count()))
+}
+
+func (d *decoder) strings() []string {
+	n := d.count()
+	if n == 0 {
+		return nil
+	}
+
+	s := make([]string, n)
+	for i := range s {
+		s[i] = d.string()
+	}
+	return s
+}
+
+func (d *decoder) position() parser.Position {
+	return parser.Position{Line: d.uvarint(), Column: d.uvarint(), Offset: d.uvarint()}
+}
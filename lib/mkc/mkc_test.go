@@ -0,0 +1,277 @@
+package mkc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"reflect"
+	"testing"
+
+	"github.com/hellozee/monkey/lib/code"
+	"github.com/hellozee/monkey/lib/compiler"
+	"github.com/hellozee/monkey/lib/conformance"
+	"github.com/hellozee/monkey/lib/object"
+	"github.com/hellozee/monkey/lib/optimizer"
+	"github.com/hellozee/monkey/lib/parser"
+	"github.com/hellozee/monkey/lib/peephole"
+	"github.com/hellozee/monkey/lib/vm"
+)
+
+// TestConformance runs every program after a round trip through the
+// file format, optimized like monkey build does.
+func TestConformance(t *testing.T) {
+	conformance.Run(t, func(prog *parser.Program, builtins *object.Builtins) object.Object {
+		c := compiler.New()
+		if err := c.Compile(optimizer.Optimize(prog, optimizer.Full)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		bytecode := c.Bytecode()
+		peephole.Optimize(bytecode)
+
+		machine := vm.New(roundtrip(t, &File{Source: "test.mk", Bytecode: bytecode}).Bytecode)
+		machine.SetBuiltins(builtins)
+		return machine.Run()
+	})
+}
+
+func TestRoundTrip(t *testing.T) {
+	input := `let a = 9223372036854775808 * 2;
+let f = fn(x) { let g = fn() { x + 1.5 }; g };
+[a, f(1)(), "text"]`
+
+	bytecode := compile(t, input)
+	file := roundtrip(t, &File{Source: "test.mk", Text: input, Bytecode: bytecode})
+
+	if file.Source != "test.mk" {
+		t.Errorf("wrong source. got=%q", file.Source)
+	}
+
+	if file.Text != input {
+		t.Errorf("wrong text. got=%q", file.Text)
+	}
+
+	if !reflect.DeepEqual(file.Bytecode.Globals, bytecode.Globals) {
+		t.Errorf("wrong globals. expected=%v, got=%v", bytecode.Globals, file.Bytecode.Globals)
+	}
+
+	if len(file.Bytecode.Constants) != len(bytecode.Constants) {
+		t.Fatalf("wrong number of constants. expected=%d, got=%d", len(bytecode.Constants), len(file.Bytecode.Constants))
+	}
+
+	for i, constant := range bytecode.Constants {
+		if !reflect.DeepEqual(file.Bytecode.Constants[i], constant) {
+			t.Errorf("constant %d wrong. expected=%#v, got=%#v", i, constant, file.Bytecode.Constants[i])
+		}
+	}
+
+	if !reflect.DeepEqual(file.Bytecode.Main, bytecode.Main) {
+		t.Errorf("main program wrong. expected=%#v, got=%#v", bytecode.Main, file.Bytecode.Main)
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, &File{Source: "test.mk", Bytecode: compile(t, `let x = fn() { 1 }; x()`)}); err != nil {
+		t.Fatalf("write error: %s", err)
+	}
+	valid := buf.Bytes()
+
+	corrupt := append([]byte{}, valid...)
+	corrupt[len(corrupt)/2] ^= 0xff
+
+	newer := append([]byte{}, valid...)
+	binary.BigEndian.PutUint16(newer[len(Magic):], Version+1)
+
+	tests := []struct {
+		name     string
+		data     []byte
+		expected string
+	}{
+		{"source", []byte("let x = 1;"), ErrNotCompiled.Error()},
+		{"empty", []byte{}, ErrNotCompiled.Error()},
+		{"header only", []byte(Magic), ErrTruncated.Error()},
+		{"version", newer, fmt.Sprintf("compiled with format version %d, this monkey reads version %d; rebuild it with `monkey build`", Version+1, Version)},
+		{"corrupt", corrupt, ErrChecksum.Error()},
+		{"truncated", valid[:len(valid)-10], ErrChecksum.Error()},
+		{"bad opcode", resum(valid, func(data []byte) []byte {
+			main := bytes.Index(data, compile(t, `let x = fn() { 1 }; x()`).Main.Instructions)
+			data[main] = 0xff
+			return data
+		}), "function 0: 0000: opcode 255 undefined"},
+	}
+
+	for _, tt := range tests {
+		_, err := Read(bytes.NewReader(tt.data))
+		if err == nil {
+			t.Errorf("%s - expected an error", tt.name)
+			continue
+		}
+
+		if err.Error() != tt.expected {
+			t.Errorf("%s - wrong error. expected=%q, got=%q", tt.name, tt.expected, err)
+		}
+	}
+
+	var versionerr *VersionError
+	if _, err := Read(bytes.NewReader(newer)); !errors.As(err, &versionerr) || versionerr.Version != Version+1 {
+		t.Errorf("expected a *VersionError. got=%v", err)
+	}
+}
+
+// TestVerify reads files that weren't written from compiled programs,
+// each would break the VM if it was run.
+func TestVerify(t *testing.T) {
+	positioned := func(fn *object.CompiledFunction, pos, end parser.Position) *object.CompiledFunction {
+		fn.Positions = code.Positions{{Offset: 0, Pos: pos, End: end}}
+		return fn
+	}
+
+	function := func(params int, locals []string, ins ...code.Instructions) *object.CompiledFunction {
+		fn := &object.CompiledFunction{NumParameters: params, Locals: locals}
+		for _, i := range ins {
+			fn.Instructions = append(fn.Instructions, i...)
+		}
+		return fn
+	}
+
+	tests := []struct {
+		name      string
+		main      *object.CompiledFunction
+		constants []object.Object
+		expected  string
+	}{
+		{
+			"no column",
+			positioned(function(0, nil, code.Make(code.OpTrue)), parser.Position{Line: 1}, parser.Position{Line: 1, Column: 5}),
+			nil,
+			"function 0: 0000: position 1:0-1:5 has no column",
+		},
+		{
+			"backwards span",
+			positioned(function(0, nil, code.Make(code.OpTrue)), parser.Position{Line: 2, Column: 3}, parser.Position{Line: 2, Column: 1}),
+			nil,
+			"function 0: 0000: position 2:3-2:1 ends before it starts",
+		},
+		{
+			"empty stack",
+			function(0, nil, code.Make(code.OpPop)),
+			nil,
+			"function 0: 0000: OpPop with the stack 0 deep",
+		},
+		{
+			"jump into an instruction",
+			function(0, nil, code.Make(code.OpConstant, 0), code.Make(code.OpJump, 1)),
+			[]object.Object{&object.Integer{Value: 1}},
+			"function 0: 0003: OpJump 1 has an invalid operand",
+		},
+		{
+			"paths disagree",
+			function(0, nil, code.Make(code.OpTrue), code.Make(code.OpJumpNotTruthy, 5), code.Make(code.OpNull), code.Make(code.OpNull)),
+			nil,
+			"function 0: 0004: OpNull leaves the stack 1 deep, another path leaves it 0 deep",
+		},
+		{
+			"main with locals",
+			function(0, []string{"a"}, code.Make(code.OpGetLocal, 0)),
+			nil,
+			"function 0: the main program can't have locals",
+		},
+		{
+			"no return",
+			function(0, nil, code.Make(code.OpClosure, 0)),
+			[]object.Object{function(0, nil, code.Make(code.OpTrue))},
+			"function 1: runs past its last instruction",
+		},
+		{
+			"outer past main",
+			function(0, nil, code.Make(code.OpClosure, 1)),
+			[]object.Object{
+				function(0, nil, code.Make(code.OpGetOuter, 3, 7), code.Make(code.OpReturnValue)),
+				function(0, nil, code.Make(code.OpClosure, 0), code.Make(code.OpReturnValue)),
+			},
+			"function 1: 0000: OpGetOuter 3 7 reads past the outermost function",
+		},
+		{
+			"outer local",
+			function(0, nil, code.Make(code.OpClosure, 1)),
+			[]object.Object{
+				function(0, nil, code.Make(code.OpGetOuter, 1, 7), code.Make(code.OpReturnValue)),
+				function(1, []string{"a"}, code.Make(code.OpClosure, 0), code.Make(code.OpReturnValue)),
+			},
+			"function 1: 0000: OpGetOuter 1 7 reads a local function 2 doesn't have",
+		},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		bytecode := &compiler.Bytecode{Main: tt.main, Constants: tt.constants}
+		if err := Write(&buf, &File{Bytecode: bytecode}); err != nil {
+			t.Fatalf("%s - write error: %s", tt.name, err)
+		}
+
+		_, err := Read(&buf)
+		if err == nil {
+			t.Errorf("%s - expected an error", tt.name)
+			continue
+		}
+
+		if err.Error() != tt.expected {
+			t.Errorf("%s - wrong error. expected=%q, got=%q", tt.name, tt.expected, err)
+		}
+	}
+}
+
+func TestIsCompiled(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, &File{Bytecode: compile(t, "1")}); err != nil {
+		t.Fatalf("write error: %s", err)
+	}
+
+	if !IsCompiled(buf.Bytes()) {
+		t.Errorf("written file is not recognized")
+	}
+
+	if IsCompiled([]byte("puts(1)")) {
+		t.Errorf("source is recognized as compiled")
+	}
+}
+
+func compile(t *testing.T, input string) *compiler.Bytecode {
+	t.Helper()
+
+	p := parser.NewParser(input)
+	prog := p.Parse()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %s", p.Errors())
+	}
+
+	c := compiler.New()
+	if err := c.Compile(prog); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	return c.Bytecode()
+}
+
+func roundtrip(t *testing.T, f *File) *File {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := Write(&buf, f); err != nil {
+		t.Fatalf("write error: %s", err)
+	}
+
+	file, err := Read(&buf)
+	if err != nil {
+		t.Fatalf("read error: %s", err)
+	}
+	return file
+}
+
+// resum changes a copy of data with edit and fixes its checksum.
+func resum(data []byte, edit func([]byte) []byte) []byte {
+	body := edit(append([]byte{}, data[:len(data)-4]...))
+	return binary.BigEndian.AppendUint32(body, crc32.ChecksumIEEE(body))
+}
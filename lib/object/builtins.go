@@ -0,0 +1,143 @@
+package object
+
+import (
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+// Variadic is the arity of builtins taking any number of arguments.
+const Variadic = -1
+
+// BuiltinFunction is the Go side of a builtin. Returning nil stands for
+// null, failures are reported by returning an *Error.
+type BuiltinFunction func(args ...Object) Object
+
+type Builtin struct {
+	Name  string
+	Arity int
+	Fn    BuiltinFunction
+}
+
+func (b *Builtin) Type() ObjectType { return BUILTIN }
+func (b *Builtin) Inspect() string  { return "builtin function " + b.Name }
+
+// Call checks the number of arguments and calls the builtin.
+func (b *Builtin) Call(args ...Object) Object {
+	if b.Arity != Variadic && len(args) != b.Arity {
+		return NewError("wrong number of arguments to `%s`: want=%d, got=%d", b.Name, b.Arity, len(args))
+	}
+	return b.Fn(args...)
+}
+
+// Builtins is a registry of native functions. Go programs embedding the
+// interpreter register their own functions on it before running scripts.
+type Builtins struct {
+	list  []*Builtin
+	index map[string]int
+}
+
+func NewBuiltins() *Builtins {
+	return &Builtins{index: make(map[string]int)}
+}
+
+// Register adds a builtin called name, replacing one already registered
+// under that name. Arity is the exact number of arguments or Variadic.
+func (b *Builtins) Register(name string, arity int, fn BuiltinFunction) {
+	builtin := &Builtin{Name: name, Arity: arity, Fn: fn}
+
+	if i, ok := b.index[name]; ok {
+		b.list[i] = builtin
+		return
+	}
+
+	b.index[name] = len(b.list)
+	b.list = append(b.list, builtin)
+}
+
+func (b *Builtins) Lookup(name string) (*Builtin, bool) {
+	i, ok := b.index[name]
+	if !ok {
+		return nil, false
+	}
+	return b.list[i], true
+}
+
+// All returns the builtins in the order they were registered.
+func (b *Builtins) All() []*Builtin {
+	return b.list
+}
+
+// DefaultBuiltins returns a registry with the standard builtins, puts
+// writes to out.
+func DefaultBuiltins(out io.Writer) *Builtins {
+	b := NewBuiltins()
+	b.Register("len", 1, builtinlen)
+	b.Register("first", 1, builtinfirst)
+	b.Register("rest", 1, builtinrest)
+	b.Register("push", 2, builtinpush)
+	b.Register("type", 1, builtintype)
+	b.Register("puts", Variadic, func(args ...Object) Object {
+		for _, arg := range args {
+			fmt.Fprintln(out, arg.Inspect())
+		}
+		return nil
+	})
+	return b
+}
+
+func builtinlen(args ...Object) Object {
+	switch arg := args[0].(type) {
+	case *String:
+		return &Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
+	case *Array:
+		return &Integer{Value: int64(len(arg.Elements))}
+	case *Hash:
+		return &Integer{Value: int64(len(arg.Pairs))}
+	default:
+		return NewError("argument to `len` not supported, got %s", arg.Type())
+	}
+}
+
+func builtinfirst(args ...Object) Object {
+	array, ok := args[0].(*Array)
+	if !ok {
+		return NewError("argument to `first` must be ARRAY, got %s", args[0].Type())
+	}
+
+	if len(array.Elements) == 0 {
+		return nil
+	}
+	return array.Elements[0]
+}
+
+func builtinrest(args ...Object) Object {
+	array, ok := args[0].(*Array)
+	if !ok {
+		return NewError("argument to `rest` must be ARRAY, got %s", args[0].Type())
+	}
+
+	if len(array.Elements) == 0 {
+		return nil
+	}
+
+	elements := make([]Object, len(array.Elements)-1)
+	copy(elements, array.Elements[1:])
+	return &Array{Elements: elements}
+}
+
+// builtinpush returns a new array, the one passed in is left untouched.
+func builtinpush(args ...Object) Object {
+	array, ok := args[0].(*Array)
+	if !ok {
+		return NewError("argument to `push` must be ARRAY, got %s", args[0].Type())
+	}
+
+	elements := make([]Object, len(array.Elements), len(array.Elements)+1)
+	copy(elements, array.Elements)
+	return &Array{Elements: append(elements, args[1])}
+}
+
+func builtintype(args ...Object) Object {
+	return &String{Value: string(args[0].Type())}
+}
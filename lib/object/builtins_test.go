@@ -0,0 +1,59 @@
+package object
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRegisterKeepsOrder(t *testing.T) {
+	b := NewBuiltins()
+	b.Register("a", 0, func(args ...Object) Object { return &Integer{Value: 1} })
+	b.Register("b", 0, func(args ...Object) Object { return &Integer{Value: 2} })
+	b.Register("a", 1, func(args ...Object) Object { return &Integer{Value: 3} })
+
+	all := b.All()
+	if len(all) != 2 || all[0].Name != "a" || all[1].Name != "b" {
+		t.Fatalf("wrong builtins. got=%v", all)
+	}
+
+	a, ok := b.Lookup("a")
+	if !ok {
+		t.Fatalf("a not found")
+	}
+
+	if a.Arity != 1 || a.Call(&Null{}).Inspect() != "3" {
+		t.Errorf("a was not replaced. got arity=%d", a.Arity)
+	}
+
+	if _, ok := b.Lookup("c"); ok {
+		t.Errorf("c should not be found")
+	}
+}
+
+func TestBuiltinArity(t *testing.T) {
+	b := &Builtin{Name: "f", Arity: 2, Fn: func(args ...Object) Object { return &Integer{Value: int64(len(args))} }}
+
+	errobj, ok := b.Call(&Null{}).(*Error)
+	if !ok || errobj.Message != "wrong number of arguments to `f`: want=2, got=1" {
+		t.Errorf("arity not checked. got=%v", b.Call(&Null{}))
+	}
+
+	b.Arity = Variadic
+	if b.Call().Inspect() != "0" || b.Call(&Null{}, &Null{}, &Null{}).Inspect() != "3" {
+		t.Errorf("variadic builtin got wrong arguments")
+	}
+}
+
+func TestPuts(t *testing.T) {
+	var out bytes.Buffer
+	puts, _ := DefaultBuiltins(&out).Lookup("puts")
+
+	result := puts.Call(&String{Value: "hello"}, &Integer{Value: 1})
+	if result != nil {
+		t.Errorf("puts should return nil. got=%v", result)
+	}
+
+	if out.String() != "hello\n1\n" {
+		t.Errorf("wrong output. got=%q", out.String())
+	}
+}
@@ -0,0 +1,56 @@
+package object
+
+import "os"
+
+type Environment struct {
+	store    map[string]Object
+	outer    *Environment
+	builtins *Builtins
+	depth    int
+}
+
+// NewEnvironment returns a top level environment with the default
+// builtins, puts writes to stdout.
+func NewEnvironment() *Environment {
+	return &Environment{store: make(map[string]Object), builtins: DefaultBuiltins(os.Stdout)}
+}
+
+func NewEnclosedEnvironment(outer *Environment) *Environment {
+	return &Environment{store: make(map[string]Object), outer: outer}
+}
+
+func (e *Environment) Get(name string) (Object, bool) {
+	obj, ok := e.store[name]
+	if !ok && e.outer != nil {
+		return e.outer.Get(name)
+	}
+	return obj, ok
+}
+
+func (e *Environment) Set(name string, val Object) Object {
+	e.store[name] = val
+	return val
+}
+
+// Builtins returns the builtins visible from e, enclosed environments
+// use the ones of their outermost environment.
+func (e *Environment) Builtins() *Builtins {
+	if e.builtins == nil && e.outer != nil {
+		return e.outer.Builtins()
+	}
+	return e.builtins
+}
+
+func (e *Environment) SetBuiltins(b *Builtins) {
+	e.builtins = b
+}
+
+// Depth returns how many function calls deep e was created, the
+// evaluator uses it to stop runaway recursion.
+func (e *Environment) Depth() int {
+	return e.depth
+}
+
+func (e *Environment) SetDepth(depth int) {
+	e.depth = depth
+}
@@ -0,0 +1,233 @@
+package object
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hellozee/monkey/lib/code"
+	"github.com/hellozee/monkey/lib/parser"
+)
+
+type ObjectType string
+
+const (
+	INTEGER  = "INTEGER"
+	FLOAT    = "FLOAT"
+	BOOLEAN  = "BOOLEAN"
+	NULL     = "NULL"
+	RETURN   = "RETURN"
+	ERROR    = "ERROR"
+	FUNCTION = "FUNCTION"
+	STRING   = "STRING"
+	ARRAY    = "ARRAY"
+	HASH     = "HASH"
+	BUILTIN  = "BUILTIN"
+
+	COMPILED_FUNCTION = "COMPILED_FUNCTION"
+)
+
+type Object interface {
+	Type() ObjectType
+	Inspect() string
+}
+
+type Integer struct {
+	Value int64
+}
+
+func (i *Integer) Type() ObjectType { return INTEGER }
+func (i *Integer) Inspect() string  { return fmt.Sprintf("%d", i.Value) }
+func (i *Integer) HashKey() HashKey { return HashKey{Type: i.Type(), Value: uint64(i.Value)} }
+
+// BigInteger holds integers that don't fit in an int64, to scripts it is
+// just another INTEGER.
+type BigInteger struct {
+	Value *big.Int
+}
+
+func (b *BigInteger) Type() ObjectType { return INTEGER }
+func (b *BigInteger) Inspect() string  { return b.Value.String() }
+
+// HashKey of a BigInteger never equals one of an Integer, values that fit
+// in an int64 are always held in an Integer.
+func (b *BigInteger) HashKey() HashKey {
+	return HashKey{Type: b.Type(), Text: b.Value.String()}
+}
+
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Type() ObjectType { return FLOAT }
+
+// Inspect always shows a decimal point or exponent so floats with an
+// integral value can't be mistaken for integers.
+func (f *Float) Inspect() string {
+	out := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if strings.ContainsAny(out, ".eIN") {
+		return out
+	}
+	return out + ".0"
+}
+
+type Boolean struct {
+	Value bool
+}
+
+func (b *Boolean) Type() ObjectType { return BOOLEAN }
+func (b *Boolean) Inspect() string  { return fmt.Sprintf("%t", b.Value) }
+
+func (b *Boolean) HashKey() HashKey {
+	if b.Value {
+		return HashKey{Type: b.Type(), Value: 1}
+	}
+	return HashKey{Type: b.Type(), Value: 0}
+}
+
+type Null struct{}
+
+func (n *Null) Type() ObjectType { return NULL }
+func (n *Null) Inspect() string  { return "null" }
+
+type ReturnValue struct {
+	Value Object
+}
+
+func (r *ReturnValue) Type() ObjectType { return RETURN }
+func (r *ReturnValue) Inspect() string  { return r.Value.Inspect() }
+
+type Error struct {
+	Message string
+	Pos     parser.Position
+	End     parser.Position
+}
+
+func (e *Error) Type() ObjectType { return ERROR }
+func (e *Error) Inspect() string  { return "ERROR: " + e.Message }
+
+func NewError(format string, a ...interface{}) *Error {
+	return &Error{Message: fmt.Sprintf(format, a...)}
+}
+
+type Function struct {
+	Parameters []*parser.Identifier
+	Body       *parser.BlockStatement
+	Env        *Environment
+	// Original is printed instead of the body when the body was
+	// rewritten, see parser.FnLiteral.
+	Original string
+}
+
+func (f *Function) Type() ObjectType { return FUNCTION }
+
+func (f *Function) Inspect() string {
+	if f.Original != "" {
+		return f.Original
+	}
+
+	var out bytes.Buffer
+
+	params := []string{}
+	for _, p := range f.Parameters {
+		params = append(params, p.String())
+	}
+
+	out.WriteString("fn(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") ")
+	out.WriteString(f.Body.String())
+
+	return out.String()
+}
+
+// CompiledFunction is a function lowered to bytecode. The first
+// NumParameters locals hold the arguments, Locals names every local slot
+// and Source is the literal it was compiled from.
+type CompiledFunction struct {
+	Instructions  code.Instructions
+	Positions     code.Positions
+	NumParameters int
+	Locals        []string
+	Source        string
+}
+
+func (c *CompiledFunction) Type() ObjectType { return COMPILED_FUNCTION }
+
+func (c *CompiledFunction) Inspect() string {
+	return fmt.Sprintf("compiled function %s", c.Source)
+}
+
+type String struct {
+	Value string
+}
+
+func (s *String) Type() ObjectType { return STRING }
+func (s *String) Inspect() string  { return s.Value }
+func (s *String) HashKey() HashKey { return HashKey{Type: s.Type(), Text: s.Value} }
+
+type Array struct {
+	Elements []Object
+}
+
+func (a *Array) Type() ObjectType { return ARRAY }
+
+func (a *Array) Inspect() string {
+	var out bytes.Buffer
+
+	elements := []string{}
+	for _, e := range a.Elements {
+		elements = append(elements, e.Inspect())
+	}
+
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+
+	return out.String()
+}
+
+// HashKey identifies a hash key by value, objects that are equal have
+// equal keys. Small values are kept in Value, the rest in Text.
+type HashKey struct {
+	Type  ObjectType
+	Value uint64
+	Text  string
+}
+
+// Hashable is implemented by the objects that can be used as hash keys.
+type Hashable interface {
+	Object
+	HashKey() HashKey
+}
+
+type HashPair struct {
+	Key   Object
+	Value Object
+}
+
+type Hash struct {
+	Pairs map[HashKey]HashPair
+}
+
+func (h *Hash) Type() ObjectType { return HASH }
+
+// Inspect lists the pairs sorted by key so the output is stable.
+func (h *Hash) Inspect() string {
+	var out bytes.Buffer
+
+	pairs := []string{}
+	for _, pair := range h.Pairs {
+		pairs = append(pairs, pair.Key.Inspect()+": "+pair.Value.Inspect())
+	}
+	sort.Strings(pairs)
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
@@ -0,0 +1,48 @@
+package object
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestStringHashKey(t *testing.T) {
+	hello1 := &String{Value: "Hello World"}
+	hello2 := &String{Value: "Hello World"}
+	diff1 := &String{Value: "My name is johnny"}
+	diff2 := &String{Value: "My name is johnny"}
+
+	if hello1.HashKey() != hello2.HashKey() {
+		t.Errorf("strings with same content have different hash keys")
+	}
+
+	if diff1.HashKey() != diff2.HashKey() {
+		t.Errorf("strings with same content have different hash keys")
+	}
+
+	if hello1.HashKey() == diff1.HashKey() {
+		t.Errorf("strings with different content have same hash keys")
+	}
+}
+
+func TestHashKeyTypes(t *testing.T) {
+	big, _ := new(big.Int).SetString("18446744073709551615", 10)
+
+	keys := []Hashable{
+		&Integer{Value: 1},
+		&Integer{Value: -1},
+		&BigInteger{Value: big},
+		&Boolean{Value: true},
+		&Boolean{Value: false},
+		&String{Value: "1"},
+		&String{Value: "true"},
+		&String{Value: ""},
+	}
+
+	seen := map[HashKey]Hashable{}
+	for _, key := range keys {
+		if other, ok := seen[key.HashKey()]; ok {
+			t.Errorf("%s %s and %s %s share a hash key", key.Type(), key.Inspect(), other.Type(), other.Inspect())
+		}
+		seen[key.HashKey()] = key
+	}
+}
@@ -0,0 +1,269 @@
+// Package optimizer rewrites parsed programs into ones that do less work
+// but evaluate to the same values and fail with the same errors.
+package optimizer
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+
+	"github.com/hellozee/monkey/lib/evaluator"
+	"github.com/hellozee/monkey/lib/object"
+	"github.com/hellozee/monkey/lib/parser"
+)
+
+// Level selects which passes run, every level includes the passes of
+// the levels below it.
+type Level int
+
+const (
+	// None leaves programs as they were parsed.
+	None Level = iota
+	// Fold computes operators whose operands are all constants.
+	Fold
+	// Full also removes the branches of ifs with constant conditions,
+	// monkey build also optimizes the bytecode at this level.
+	Full
+)
+
+const Default = Full
+
+// ParseLevel reads a level written as a number, like the n of -On.
+func ParseLevel(s string) (Level, error) {
+	switch s {
+	case "0":
+		return None, nil
+	case "1":
+		return Fold, nil
+	case "2":
+		return Full, nil
+	}
+	return None, fmt.Errorf("unknown optimization level %q", s)
+}
+
+// Pass is a rewrite of expressions. Rewrite is called on every
+// expression after its children were rewritten and returns the
+// expression to put in its place, or expr itself to keep it. It may
+// change expr in place.
+type Pass struct {
+	Name    string
+	Level   Level
+	Rewrite func(expr parser.Expression) parser.Expression
+}
+
+type Optimizer struct {
+	level  Level
+	passes []Pass
+}
+
+// New returns an optimizer running the built-in passes enabled at level.
+func New(level Level) *Optimizer {
+	o := &Optimizer{level: level}
+	o.Add(Pass{Name: "fold", Level: Fold, Rewrite: fold})
+	o.Add(Pass{Name: "deadbranches", Level: Full, Rewrite: deadbranches})
+	return o
+}
+
+// Add appends a pass, it only runs when the optimizer's level is at
+// least the pass's level.
+func (o *Optimizer) Add(pass Pass) {
+	if pass.Level <= o.level {
+		o.passes = append(o.passes, pass)
+	}
+}
+
+// Optimize rewrites the program in place and returns it.
+func (o *Optimizer) Optimize(prog *parser.Program) *parser.Program {
+	if len(o.passes) != 0 {
+		for _, stmt := range prog.Statements {
+			o.statement(stmt)
+		}
+	}
+	return prog
+}
+
+// Optimize runs the built-in passes enabled at level on prog.
+func Optimize(prog *parser.Program, level Level) *parser.Program {
+	return New(level).Optimize(prog)
+}
+
+func (o *Optimizer) statement(stmt parser.Statement) {
+	switch stmt := stmt.(type) {
+	case *parser.LetStatement:
+		stmt.Value = o.expr(stmt.Value)
+	case *parser.ReturnStatement:
+		stmt.Value = o.expr(stmt.Value)
+	case *parser.ExpressionStatement:
+		stmt.Expr = o.expr(stmt.Expr)
+	case *parser.BlockStatement:
+		o.block(stmt)
+	}
+}
+
+func (o *Optimizer) block(block *parser.BlockStatement) {
+	if block == nil {
+		return
+	}
+	for _, stmt := range block.Statements {
+		o.statement(stmt)
+	}
+}
+
+// expr rewrites the children of expr, then runs the passes on it.
+func (o *Optimizer) expr(expr parser.Expression) parser.Expression {
+	switch node := expr.(type) {
+	case nil:
+		return nil
+	case *parser.PrefixExpr:
+		node.Right = o.expr(node.Right)
+	case *parser.InfixExpr:
+		node.Left = o.expr(node.Left)
+		node.Right = o.expr(node.Right)
+	case *parser.IfExpr:
+		node.Condition = o.expr(node.Condition)
+		o.block(node.Consequence)
+		o.block(node.Alternative)
+	case *parser.FnLiteral:
+		// Functions print the way they were written, not optimized.
+		if node.Original == "" {
+			node.Original = node.String()
+		}
+		o.block(node.Body)
+	case *parser.CallExpr:
+		node.Function = o.expr(node.Function)
+		o.exprs(node.Arguments)
+	case *parser.ArrayLiteral:
+		o.exprs(node.Elements)
+	case *parser.IndexExpr:
+		node.Left = o.expr(node.Left)
+		node.Index = o.expr(node.Index)
+	case *parser.SliceExpr:
+		node.Left = o.expr(node.Left)
+		node.Low = o.expr(node.Low)
+		node.High = o.expr(node.High)
+	case *parser.HashLiteral:
+		for i := range node.Pairs {
+			node.Pairs[i].Key = o.expr(node.Pairs[i].Key)
+			node.Pairs[i].Value = o.expr(node.Pairs[i].Value)
+		}
+	}
+
+	for _, pass := range o.passes {
+		expr = pass.Rewrite(expr)
+	}
+	return expr
+}
+
+func (o *Optimizer) exprs(exprs []parser.Expression) {
+	for i, expr := range exprs {
+		exprs[i] = o.expr(expr)
+	}
+}
+
+// fold replaces operators on constants with their result, using the
+// evaluator's operators so the result is the one the program would have
+// computed. Operators that fail are kept to fail at run time.
+func fold(expr parser.Expression) parser.Expression {
+	var result object.Object
+
+	switch node := expr.(type) {
+	case *parser.PrefixExpr:
+		right, ok := constant(node.Right)
+		if !ok {
+			return expr
+		}
+		result = evaluator.Prefix(node.Operator, right)
+	case *parser.InfixExpr:
+		left, ok := constant(node.Left)
+		if !ok {
+			return expr
+		}
+		right, ok := constant(node.Right)
+		if !ok {
+			return expr
+		}
+		result = evaluator.Infix(node.Operator, left, right)
+	default:
+		return expr
+	}
+
+	if lit := literal(result, expr.Pos(), expr.End()); lit != nil {
+		return lit
+	}
+	return expr
+}
+
+// deadbranches removes the branch of an if that its constant condition
+// never takes. When the branch left is a single literal the if is
+// replaced by it, the literal gets the if's span so errors pointing at
+// the if, like an unusable hash key, don't move.
+func deadbranches(expr parser.Expression) parser.Expression {
+	node, ok := expr.(*parser.IfExpr)
+	if !ok {
+		return expr
+	}
+
+	condition, ok := constant(node.Condition)
+	if !ok {
+		return expr
+	}
+
+	live := node.Consequence
+	if evaluator.Truthy(condition) {
+		node.Alternative = nil
+	} else {
+		live = node.Alternative
+		node.Consequence.Statements = nil
+	}
+
+	if live != nil && len(live.Statements) == 1 {
+		if stmt, ok := live.Statements[0].(*parser.ExpressionStatement); ok {
+			if value, ok := constant(stmt.Expr); ok {
+				if lit := literal(value, node.Pos(), node.End()); lit != nil {
+					return lit
+				}
+			}
+		}
+	}
+	return node
+}
+
+// constant returns the value of a literal, for anything else it returns
+// false.
+func constant(expr parser.Expression) (object.Object, bool) {
+	switch node := expr.(type) {
+	case *parser.IntLiteral:
+		if node.Big != nil {
+			return &object.BigInteger{Value: node.Big}, true
+		}
+		return &object.Integer{Value: node.Value}, true
+	case *parser.FloatLiteral:
+		return &object.Float{Value: node.Value}, true
+	case *parser.StringLiteral:
+		return &object.String{Value: node.Value}, true
+	case *parser.BoolExpr:
+		return evaluator.Bool(node.Value), true
+	}
+	return nil, false
+}
+
+// literal turns a value back into a literal, it returns nil for values
+// that can't be written as one.
+func literal(obj object.Object, pos, end parser.Position) parser.Expression {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return parser.NewIntLiteral(big.NewInt(obj.Value), pos, end)
+	case *object.BigInteger:
+		return parser.NewIntLiteral(obj.Value, pos, end)
+	case *object.Float:
+		if math.IsInf(obj.Value, 0) || math.IsNaN(obj.Value) {
+			return nil
+		}
+		return parser.NewFloatLiteral(obj.Value, pos, end)
+	case *object.String:
+		return parser.NewStringLiteral(obj.Value, pos, end)
+	case *object.Boolean:
+		return parser.NewBoolExpr(obj.Value, pos, end)
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package optimizer
+
+import (
+	"testing"
+
+	"github.com/hellozee/monkey/lib/compiler"
+	"github.com/hellozee/monkey/lib/conformance"
+	"github.com/hellozee/monkey/lib/evaluator"
+	"github.com/hellozee/monkey/lib/object"
+	"github.com/hellozee/monkey/lib/parser"
+	"github.com/hellozee/monkey/lib/vm"
+)
+
+type optimizertestcase struct {
+	input    string
+	expected string
+}
+
+func TestFold(t *testing.T) {
+	tests := []optimizertestcase{
+		{"(2 * 3) + -(-4)", "10"},
+		{"1 + 2 + x", "(3 + x)"},
+		{"x + 1 + 2", "((x + 1) + 2)"},
+		{"7 / 2", "3"},
+		{"1 / 0", "(1 / 0)"},
+		{"1 + true", "(1 + true)"},
+		{"-true", "(-true)"},
+		{"!5", "false"},
+		{"!!true == (1 < 2)", "true"},
+		{"1.5 * 2", "3.0"},
+		{"1.0 / 3.0 * 3.0", "1.0"},
+		{"2.5e300 * 2.0", "5e+300"},
+		{"9223372036854775807 + 1", "9223372036854775808"},
+		{"9223372036854775808 - 1", "9223372036854775807"},
+		{`"mon" + "key"`, `"monkey"`},
+		{`"a\n" + "b"`, `"a\nb"`},
+		{`"a" == "a"`, "true"},
+		{"fn(x) { x * (60 * 60) }", "fn(x) { (x * 3600) }"},
+		{"let a = [1 + 1, 2][0 + 1];", "let a = ([2, 2][1]);"},
+		{"f(1 + 1)[1 - 1:]", "(f(2)[0:])"},
+		{`{"a" + "b": -1}`, `{"ab": -1}`},
+		{"return 2 * 2;", "return 4;"},
+		{"if (1 < 2) { 1 } else { 2 }", "if (true) { 1 } else { 2 }"},
+	}
+
+	runoptimizertests(t, Fold, tests)
+}
+
+func TestDeadBranches(t *testing.T) {
+	tests := []optimizertestcase{
+		{"if (1 < 2) { 1 } else { 2 }", "1"},
+		{"if (false) { 1 } else { 2 + 2 }", "4"},
+		{"if (true) { x + 1 } else { 2 }", "if (true) { (x + 1) }"},
+		{"if (false) { 1 }", "if (false) {}"},
+		{"if (0) { let a = 1; a } else { 2 }", "if (0) { let a = 1; a }"},
+		{"if (!true) { 1 } else { let b = 2; b }", "if (false) {} else { let b = 2; b }"},
+		{"if (true) { return 1; }", "if (true) { return 1; }"},
+		{"if (x) { 1 } else { 2 }", "if (x) { 1 } else { 2 }"},
+		{"if (true) { if (false) { 1 } else { 2 } }", "2"},
+		{"fn() { if (1 == 1) { x } }", "fn() { if (true) { x } }"},
+	}
+
+	runoptimizertests(t, Full, tests)
+}
+
+func TestNone(t *testing.T) {
+	runoptimizertests(t, None, []optimizertestcase{
+		{"(2 * 3) + -(-4)", "((2 * 3) + (-(-4)))"},
+		{"if (true) { 1 }", "if (true) { 1 }"},
+	})
+}
+
+func TestFoldedSpan(t *testing.T) {
+	prog := Optimize(parse(t, "x + (2 * 3)"), Full)
+
+	infix := prog.Statements[0].(*parser.ExpressionStatement).Expr.(*parser.InfixExpr)
+	lit, ok := infix.Right.(*parser.IntLiteral)
+	if !ok {
+		t.Fatalf("right operand is not folded. got=%T", infix.Right)
+	}
+
+	if lit.Pos().String() != "1:6" || lit.End().String() != "1:11" {
+		t.Errorf("wrong span. got=%s-%s", lit.Pos(), lit.End())
+	}
+}
+
+func TestDeadBranchSpan(t *testing.T) {
+	prog := Optimize(parse(t, "x + if (true) { 2.5 }"), Full)
+
+	infix := prog.Statements[0].(*parser.ExpressionStatement).Expr.(*parser.InfixExpr)
+	lit, ok := infix.Right.(*parser.FloatLiteral)
+	if !ok {
+		t.Fatalf("if is not replaced. got=%T", infix.Right)
+	}
+
+	if lit.Pos().String() != "1:5" || lit.End().String() != "1:22" {
+		t.Errorf("wrong span. got=%s-%s", lit.Pos(), lit.End())
+	}
+}
+
+func TestOriginalFunction(t *testing.T) {
+	prog := Optimize(parse(t, "fn() { fn() { 1 + 2 } }"), Full)
+
+	outer := prog.Statements[0].(*parser.ExpressionStatement).Expr.(*parser.FnLiteral)
+	inner := outer.Body.Statements[0].(*parser.ExpressionStatement).Expr.(*parser.FnLiteral)
+
+	if outer.String() != "fn() { fn() { 3 } }" {
+		t.Errorf("body not optimized. got=%q", outer.String())
+	}
+
+	if outer.Source() != "fn() { fn() { (1 + 2) } }" {
+		t.Errorf("outer source wrong. got=%q", outer.Source())
+	}
+
+	if inner.Source() != "fn() { (1 + 2) }" {
+		t.Errorf("inner source wrong. got=%q", inner.Source())
+	}
+}
+
+func TestAdd(t *testing.T) {
+	var seen []string
+	record := func(name string) Pass {
+		return Pass{Name: name, Level: Full, Rewrite: func(expr parser.Expression) parser.Expression {
+			seen = append(seen, name+" "+expr.String())
+			return expr
+		}}
+	}
+
+	o := New(Full)
+	o.Add(record("full"))
+	New(Fold).Add(record("skipped"))
+
+	o.Optimize(parse(t, "-(1 + 2)"))
+
+	expected := []string{"full 1", "full 2", "full 3", "full -3"}
+	if len(seen) != len(expected) {
+		t.Fatalf("wrong calls. expected=%q, got=%q", expected, seen)
+	}
+	for i := range expected {
+		if seen[i] != expected[i] {
+			t.Errorf("call %d wrong. expected=%q, got=%q", i, expected[i], seen[i])
+		}
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := map[string]Level{"0": None, "1": Fold, "2": Full}
+
+	for input, expected := range tests {
+		if level, err := ParseLevel(input); err != nil || level != expected {
+			t.Errorf("ParseLevel(%q) wrong. expected=%d, got=%d (%v)", input, expected, level, err)
+		}
+	}
+
+	if _, err := ParseLevel("3"); err == nil || err.Error() != `unknown optimization level "3"` {
+		t.Errorf("wrong error. got=%v", err)
+	}
+}
+
+// The conformance suite checks that optimized programs still give the
+// same results and errors, at the same positions, on both backends.
+
+func TestConformanceEvaluator(t *testing.T) {
+	conformance.Run(t, func(prog *parser.Program, builtins *object.Builtins) object.Object {
+		env := object.NewEnvironment()
+		env.SetBuiltins(builtins)
+		return evaluator.Eval(Optimize(prog, Full), env)
+	})
+}
+
+func TestConformanceVM(t *testing.T) {
+	conformance.Run(t, func(prog *parser.Program, builtins *object.Builtins) object.Object {
+		c := compiler.New()
+		if err := c.Compile(Optimize(prog, Full)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		machine := vm.New(c.Bytecode())
+		machine.SetBuiltins(builtins)
+		return machine.Run()
+	})
+}
+
+func parse(t *testing.T, input string) *parser.Program {
+	t.Helper()
+
+	p := parser.NewParser(input)
+	prog := p.Parse()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %s", p.Errors())
+	}
+	return prog
+}
+
+func runoptimizertests(t *testing.T, level Level, tests []optimizertestcase) {
+	t.Helper()
+
+	for _, tt := range tests {
+		prog := Optimize(parse(t, tt.input), level)
+
+		if prog.String() != tt.expected {
+			t.Errorf("%q - wrong program. expected=%q, got=%q", tt.input, tt.expected, prog.String())
+		}
+	}
+}
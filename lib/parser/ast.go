@@ -2,62 +2,98 @@ package parser
 
 import (
 	"bytes"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
 )
 
-type node interface {
+type Node interface {
 	tokenliteral() string
-	tostring() string
+	String() string
+	Pos() Position
+	End() Position
 }
 
-type statement interface {
-	node
+type Statement interface {
+	Node
 	statementnode()
 }
 
-type expression interface {
-	node
+type Expression interface {
+	Node
 	expressionnode()
 }
 
-type program struct {
-	statements []statement
+// span records where a node starts and ends in the source, the end is
+// exclusive and points just past the last character of the node.
+type span struct {
+	start Position
+	end   Position
 }
 
-func (p *program) tokenliteral() string {
-	if len(p.statements) > 0 {
-		return p.statements[0].tokenliteral()
+func (s span) Pos() Position { return s.start }
+func (s span) End() Position { return s.end }
+
+// Comment is a // or /* */ comment, Text includes the comment markers.
+type Comment struct {
+	span
+	Text string
+}
+
+type Program struct {
+	span
+	Statements []Statement
+	Comments   []*Comment
+}
+
+func (p *Program) tokenliteral() string {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].tokenliteral()
 	}
 	return ""
 }
 
-func (p *program) tostring() string {
+func (p *Program) String() string {
 	var out bytes.Buffer
 
-	for _, s := range p.statements {
-		out.WriteString(s.tostring())
+	for _, s := range p.Statements {
+		out.WriteString(s.String())
 	}
 
 	return out.String()
 }
 
-type letstatement struct {
+// BadStatement stands in for a statement that failed to parse, it covers
+// the tokens skipped while recovering from the error.
+type BadStatement struct {
+	span
+	tok token
+}
+
+func (b *BadStatement) statementnode()       {}
+func (b *BadStatement) tokenliteral() string { return b.tok.literal }
+func (b *BadStatement) String() string       { return "<bad statement>" }
+
+type LetStatement struct {
+	span
 	tok   token
-	name  *identifier
-	value expression
+	Name  *Identifier
+	Value Expression
 }
 
-func (l *letstatement) statementnode()       {}
-func (l *letstatement) tokenliteral() string { return l.tok.literal }
+func (l *LetStatement) statementnode()       {}
+func (l *LetStatement) tokenliteral() string { return l.tok.literal }
 
-func (l *letstatement) tostring() string {
+func (l *LetStatement) String() string {
 	var out bytes.Buffer
 
 	out.WriteString(l.tokenliteral() + " ")
-	out.WriteString(l.name.tostring())
+	out.WriteString(l.Name.String())
 	out.WriteString(" = ")
 
-	if l.value != nil {
-		out.WriteString(l.value.tostring())
+	if l.Value != nil {
+		out.WriteString(l.Value.String())
 	}
 
 	out.WriteString(";")
@@ -65,19 +101,20 @@ func (l *letstatement) tostring() string {
 	return out.String()
 }
 
-type returnstatement struct {
+type ReturnStatement struct {
+	span
 	tok   token
-	value expression
+	Value Expression
 }
 
-func (r *returnstatement) statementnode()       {}
-func (r *returnstatement) tokenliteral() string { return r.tok.literal }
+func (r *ReturnStatement) statementnode()       {}
+func (r *ReturnStatement) tokenliteral() string { return r.tok.literal }
 
-func (r *returnstatement) tostring() string {
+func (r *ReturnStatement) String() string {
 	var out bytes.Buffer
-	out.WriteString(r.tokenliteral() + " ")
-	if r.value != nil {
-		out.WriteString(r.value.tostring())
+	out.WriteString(r.tokenliteral())
+	if r.Value != nil {
+		out.WriteString(" " + r.Value.String())
 	}
 
 	out.WriteString(";")
@@ -85,82 +122,395 @@ func (r *returnstatement) tostring() string {
 	return out.String()
 }
 
-type expressionstatement struct {
+type ExpressionStatement struct {
+	span
 	tok  token
-	expr expression
+	Expr Expression
 }
 
-func (e *expressionstatement) statementnode()       {}
-func (e *expressionstatement) tokenliteral() string { return e.tok.literal }
+func (e *ExpressionStatement) statementnode()       {}
+func (e *ExpressionStatement) tokenliteral() string { return e.tok.literal }
 
-func (e *expressionstatement) tostring() string {
-	if e.expr != nil {
-		return e.expr.tostring()
+func (e *ExpressionStatement) String() string {
+	if e.Expr != nil {
+		return e.Expr.String()
 	}
 	return ""
 }
 
-type identifier struct {
+type Identifier struct {
+	span
+	tok   token
+	Value string
+}
+
+func (i *Identifier) expressionnode()      {}
+func (i *Identifier) tokenliteral() string { return i.tok.literal }
+func (i *Identifier) String() string       { return i.Value }
+
+// IntLiteral holds its value in Value, literals too large for an int64
+// are kept in Big instead.
+type IntLiteral struct {
+	span
+	tok   token
+	Value int64
+	Big   *big.Int
+}
+
+func (i *IntLiteral) expressionnode()      {}
+func (i *IntLiteral) tokenliteral() string { return i.tok.literal }
+func (i *IntLiteral) String() string       { return i.tok.literal }
+
+type FloatLiteral struct {
+	span
 	tok   token
-	value string
+	Value float64
 }
 
-func (i *identifier) expressionnode()      {}
-func (i *identifier) tokenliteral() string { return i.tok.literal }
-func (i *identifier) tostring() string     { return i.value }
+func (f *FloatLiteral) expressionnode()      {}
+func (f *FloatLiteral) tokenliteral() string { return f.tok.literal }
+func (f *FloatLiteral) String() string       { return f.tok.literal }
 
-type intliteral struct {
+type StringLiteral struct {
+	span
 	tok   token
-	value int64
+	Value string
 }
 
-func (i *intliteral) expressionnode()      {}
-func (i *intliteral) tokenliteral() string { return i.tok.literal }
-func (i *intliteral) tostring() string     { return i.tok.literal }
+func (s *StringLiteral) expressionnode()      {}
+func (s *StringLiteral) tokenliteral() string { return s.tok.literal }
+func (s *StringLiteral) String() string       { return quote(s.Value) }
 
-type prefixexpr struct {
+// quote turns a string value back into a literal the lexer accepts.
+func quote(value string) string {
+	var out bytes.Buffer
+	out.WriteByte('"')
+
+	for _, char := range value {
+		switch char {
+		case '\n':
+			out.WriteString("\\n")
+		case '\t':
+			out.WriteString("\\t")
+		case '"':
+			out.WriteString("\\\"")
+		case '\\':
+			out.WriteString("\\\\")
+		default:
+			if char < ' ' || char == 0x7f {
+				fmt.Fprintf(&out, "\\u{%x}", char)
+			} else {
+				out.WriteRune(char)
+			}
+		}
+	}
+
+	out.WriteByte('"')
+	return out.String()
+}
+
+type PrefixExpr struct {
+	span
 	tok      token
-	operator string
-	right    expression
+	Operator string
+	Right    Expression
 }
 
-func (p *prefixexpr) expressionnode()      {}
-func (p *prefixexpr) tokenliteral() string { return p.tok.literal }
+func (p *PrefixExpr) expressionnode()      {}
+func (p *PrefixExpr) tokenliteral() string { return p.tok.literal }
 
-func (p *prefixexpr) tostring() string {
+func (p *PrefixExpr) String() string {
 	var out bytes.Buffer
 	out.WriteString("(")
-	out.WriteString(p.operator)
-	out.WriteString(p.right.tostring())
+	out.WriteString(p.Operator)
+	out.WriteString(p.Right.String())
 	out.WriteString(")")
 	return out.String()
 }
 
-type infixexpr struct {
+type InfixExpr struct {
+	span
 	tok      token
-	left     expression
-	operator string
-	right    expression
+	Left     Expression
+	Operator string
+	Right    Expression
+}
+
+func (i *InfixExpr) expressionnode()      {}
+func (i *InfixExpr) tokenliteral() string { return i.tok.literal }
+
+func (i *InfixExpr) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(i.Left.String())
+	out.WriteString(" " + i.Operator + " ")
+	out.WriteString(i.Right.String())
+	out.WriteString(")")
+	return out.String()
+}
+
+type BoolExpr struct {
+	span
+	tok   token
+	Value bool
+}
+
+func (b *BoolExpr) expressionnode()      {}
+func (b *BoolExpr) tokenliteral() string { return b.tok.literal }
+func (b *BoolExpr) String() string       { return b.tok.literal }
+
+type BlockStatement struct {
+	span
+	tok        token
+	Statements []Statement
+}
+
+func (b *BlockStatement) statementnode()       {}
+func (b *BlockStatement) tokenliteral() string { return b.tok.literal }
+
+func (b *BlockStatement) String() string {
+	if len(b.Statements) == 0 {
+		return "{}"
+	}
+
+	var out bytes.Buffer
+	out.WriteString("{ ")
+
+	for _, s := range b.Statements {
+		out.WriteString(s.String())
+		out.WriteString(" ")
+	}
+
+	out.WriteString("}")
+	return out.String()
+}
+
+type IfExpr struct {
+	span
+	tok         token
+	Condition   Expression
+	Consequence *BlockStatement
+	Alternative *BlockStatement
+}
+
+func (i *IfExpr) expressionnode()      {}
+func (i *IfExpr) tokenliteral() string { return i.tok.literal }
+
+func (i *IfExpr) String() string {
+	var out bytes.Buffer
+	out.WriteString("if (")
+	out.WriteString(i.Condition.String())
+	out.WriteString(") ")
+	out.WriteString(i.Consequence.String())
+
+	if i.Alternative != nil {
+		out.WriteString(" else ")
+		out.WriteString(i.Alternative.String())
+	}
+
+	return out.String()
+}
+
+type FnLiteral struct {
+	span
+	tok        token
+	Parameters []*Identifier
+	Body       *BlockStatement
+	// Original is the literal as it was parsed, rewrites of Body like
+	// the optimizer's set it so the function still prints the way it
+	// was written. It is empty when Body wasn't rewritten.
+	Original string
+}
+
+func (f *FnLiteral) expressionnode()      {}
+func (f *FnLiteral) tokenliteral() string { return f.tok.literal }
+
+// Source returns the literal as it was parsed.
+func (f *FnLiteral) Source() string {
+	if f.Original != "" {
+		return f.Original
+	}
+	return f.String()
+}
+
+func (f *FnLiteral) String() string {
+	var out bytes.Buffer
+
+	params := []string{}
+	for _, p := range f.Parameters {
+		params = append(params, p.String())
+	}
+
+	out.WriteString(f.tokenliteral())
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") ")
+	out.WriteString(f.Body.String())
+
+	return out.String()
+}
+
+type CallExpr struct {
+	span
+	tok       token
+	Function  Expression
+	Arguments []Expression
 }
 
-func (i *infixexpr) expressionnode()      {}
-func (i *infixexpr) tokenliteral() string { return i.tok.literal }
+func (c *CallExpr) expressionnode()      {}
+func (c *CallExpr) tokenliteral() string { return c.tok.literal }
 
-func (i *infixexpr) tostring() string {
+func (c *CallExpr) String() string {
 	var out bytes.Buffer
+
+	args := []string{}
+	for _, a := range c.Arguments {
+		args = append(args, a.String())
+	}
+
+	out.WriteString(c.Function.String())
 	out.WriteString("(")
-	out.WriteString(i.left.tostring())
-	out.WriteString(" " + i.operator + " ")
-	out.WriteString(i.right.tostring())
+	out.WriteString(strings.Join(args, ", "))
 	out.WriteString(")")
+
+	return out.String()
+}
+
+type ArrayLiteral struct {
+	span
+	tok      token
+	Elements []Expression
+}
+
+func (a *ArrayLiteral) expressionnode()      {}
+func (a *ArrayLiteral) tokenliteral() string { return a.tok.literal }
+
+func (a *ArrayLiteral) String() string {
+	var out bytes.Buffer
+
+	elements := []string{}
+	for _, e := range a.Elements {
+		elements = append(elements, e.String())
+	}
+
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+
+	return out.String()
+}
+
+type IndexExpr struct {
+	span
+	tok   token
+	Left  Expression
+	Index Expression
+}
+
+func (i *IndexExpr) expressionnode()      {}
+func (i *IndexExpr) tokenliteral() string { return i.tok.literal }
+
+func (i *IndexExpr) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(i.Left.String())
+	out.WriteString("[")
+	out.WriteString(i.Index.String())
+	out.WriteString("])")
 	return out.String()
 }
 
-type boolexpr struct {
+// SliceExpr is left[Low:High], either bound may be left out and is nil
+// in that case.
+type SliceExpr struct {
+	span
+	tok  token
+	Left Expression
+	Low  Expression
+	High Expression
+}
+
+func (s *SliceExpr) expressionnode()      {}
+func (s *SliceExpr) tokenliteral() string { return s.tok.literal }
+
+func (s *SliceExpr) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(s.Left.String())
+	out.WriteString("[")
+	if s.Low != nil {
+		out.WriteString(s.Low.String())
+	}
+	out.WriteString(":")
+	if s.High != nil {
+		out.WriteString(s.High.String())
+	}
+	out.WriteString("])")
+	return out.String()
+}
+
+// HashLiteral is a {key: value} literal, Pairs keeps the order of the
+// source.
+type HashLiteral struct {
+	span
 	tok   token
-	value bool
+	Pairs []HashPair
+}
+
+type HashPair struct {
+	Key   Expression
+	Value Expression
+}
+
+func (h *HashLiteral) expressionnode()      {}
+func (h *HashLiteral) tokenliteral() string { return h.tok.literal }
+
+func (h *HashLiteral) String() string {
+	var out bytes.Buffer
+
+	pairs := []string{}
+	for _, pair := range h.Pairs {
+		pairs = append(pairs, pair.Key.String()+": "+pair.Value.String())
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
+// The constructors below build literals for tools that rewrite trees,
+// like the optimizer. The literal spans pos to end.
+
+// NewIntLiteral keeps value in Value when it fits an int64, in Big
+// otherwise, like the parser does.
+func NewIntLiteral(value *big.Int, pos, end Position) *IntLiteral {
+	lit := &IntLiteral{span: span{pos, end}, tok: token{ttype: INT, literal: value.String(), start: pos, end: end}}
+	if value.IsInt64() {
+		lit.Value = value.Int64()
+	} else {
+		lit.Big = value
+	}
+	return lit
+}
+
+func NewFloatLiteral(value float64, pos, end Position) *FloatLiteral {
+	literal := strconv.FormatFloat(value, 'g', -1, 64)
+	if !strings.ContainsAny(literal, ".eIN") {
+		literal += ".0"
+	}
+	return &FloatLiteral{span: span{pos, end}, tok: token{ttype: FLOAT, literal: literal, start: pos, end: end}, Value: value}
 }
 
-func (b *boolexpr) expressionnode()      {}
-func (b *boolexpr) tokenliteral() string { return b.tok.literal }
-func (b *boolexpr) tostring() string     { return b.tok.literal }
+func NewStringLiteral(value string, pos, end Position) *StringLiteral {
+	return &StringLiteral{span: span{pos, end}, tok: token{ttype: STRING, literal: value, start: pos, end: end}, Value: value}
+}
+
+func NewBoolExpr(value bool, pos, end Position) *BoolExpr {
+	tok := token{ttype: FALSE, literal: "false", start: pos, end: end}
+	if value {
+		tok.ttype, tok.literal = TRUE, "true"
+	}
+	return &BoolExpr{span: span{pos, end}, tok: tok, Value: value}
+}
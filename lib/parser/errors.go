@@ -0,0 +1,83 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+type ErrorCode string
+
+const (
+	ErrUnexpectedToken ErrorCode = "E0001"
+	ErrNoPrefix        ErrorCode = "E0002"
+	ErrUnexpectedEOF   ErrorCode = "E0003"
+	ErrInvalidInteger  ErrorCode = "E0004"
+	ErrIllegalToken    ErrorCode = "E0005"
+	ErrUnterminated    ErrorCode = "E0006"
+	ErrInvalidEscape   ErrorCode = "E0007"
+	ErrInvalidFloat    ErrorCode = "E0008"
+	ErrMalformedNumber ErrorCode = "E0009"
+)
+
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	default:
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+}
+
+// ParseError is a single diagnostic reported by the parser or the lexer. Expected is
+// only set when the parser was looking for a specific token, Actual is
+// the token it found instead. Hint is an optional suggestion for a fix.
+type ParseError struct {
+	Pos      Position
+	End      Position
+	Code     ErrorCode
+	Severity Severity
+	Expected TokenType
+	Actual   TokenType
+	Message  string
+	Hint     string
+}
+
+func (e *ParseError) Error() string {
+	return e.Pos.String() + ": " + e.Message
+}
+
+// ErrorList collects every diagnostic of a parse in source order.
+type ErrorList []*ParseError
+
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	}
+
+	msgs := make([]string, len(l))
+	for i, e := range l {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Err returns nil for an empty list so callers can use the usual
+// if err != nil check.
+func (l ErrorList) Err() error {
+	if len(l) == 0 {
+		return nil
+	}
+	return l
+}
@@ -0,0 +1,82 @@
+package parser
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseErrorDetails(t *testing.T) {
+	tests := []struct {
+		input    string
+		code     ErrorCode
+		expected TokenType
+		actual   TokenType
+		pos      string
+		end      string
+	}{
+		{"let 5 = x;", ErrUnexpectedToken, IDENT, INT, "1:5", "1:6"},
+		{"(1 + 2;", ErrUnexpectedToken, RPAREN, SEMICOLON, "1:7", "1:8"},
+		{"let x =", ErrUnexpectedEOF, "", EOF, "1:8", "1:9"},
+		{"=", ErrNoPrefix, "", ASSIGN, "1:1", "1:2"},
+		{"0b1012", ErrInvalidInteger, "", INT, "1:1", "1:7"},
+	}
+
+	for _, tt := range tests {
+		p := NewParser(tt.input)
+		p.Parse()
+
+		if len(p.Errors()) == 0 {
+			t.Fatalf("no errors for %q", tt.input)
+		}
+
+		err := p.Errors()[0]
+
+		if err.Code != tt.code {
+			t.Errorf("wrong code for %q. expected=%s, got=%s", tt.input, tt.code, err.Code)
+		}
+
+		if err.Severity != SeverityError {
+			t.Errorf("wrong severity for %q. got=%s", tt.input, err.Severity)
+		}
+
+		if err.Expected != tt.expected {
+			t.Errorf("wrong expected token for %q. expected=%q, got=%q", tt.input, tt.expected, err.Expected)
+		}
+
+		if err.Actual != tt.actual {
+			t.Errorf("wrong actual token for %q. expected=%q, got=%q", tt.input, tt.actual, err.Actual)
+		}
+
+		if err.Pos.String() != tt.pos || err.End.String() != tt.end {
+			t.Errorf("wrong span for %q. expected=%s-%s, got=%s-%s", tt.input, tt.pos, tt.end, err.Pos, err.End)
+		}
+	}
+}
+
+func TestErrorList(t *testing.T) {
+	p := NewParser("let x = 5;")
+	p.Parse()
+
+	if err := p.Errors().Err(); err != nil {
+		t.Fatalf("Err() for a clean parse is not nil. got=%v", err)
+	}
+
+	p = NewParser("let = 5; let y")
+	p.Parse()
+
+	err := p.Errors().Err()
+	if err == nil {
+		t.Fatalf("Err() returned nil for a failed parse")
+	}
+
+	expected := "1:5: expected next token is IDENT, got = instead\n" +
+		"1:15: expected next token is =, got EOF instead"
+	if err.Error() != expected {
+		t.Errorf("err.Error() wrong. expected=%q, got=%q", expected, err.Error())
+	}
+
+	var list ErrorList
+	if !errors.As(err, &list) || len(list) != 2 {
+		t.Errorf("err does not unwrap to an ErrorList of 2 errors. got=%#v", err)
+	}
+}
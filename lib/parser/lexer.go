@@ -1,33 +1,66 @@
 package parser
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
 type lexer struct {
 	input   string
 	pos     int
 	readPos int
-	char    byte
+	char    rune
+	line    int
+	col     int
+
+	comments []*Comment
 }
 
 func newlexer(data string) *lexer {
-	temp := lexer{input: data}
+	temp := lexer{input: data, line: 1}
 	temp.read()
 	return &temp
 }
 
 func (l *lexer) read() {
+	if l.char == '\n' {
+		l.line++
+		l.col = 0
+	}
+	l.col++
+
+	l.pos = l.readPos
+
 	if l.readPos >= len(l.input) {
 		l.char = 0
-	} else {
-		l.char = l.input[l.readPos]
+		l.readPos++
+		return
 	}
 
-	l.pos = l.readPos
-	l.readPos++
+	char, width := rune(l.input[l.readPos]), 1
+	if char >= utf8.RuneSelf {
+		char, width = utf8.DecodeRuneInString(l.input[l.readPos:])
+	}
+
+	l.char = char
+	l.readPos += width
 }
 
 func (l *lexer) next() token {
 	var tok token
 
-	l.skipspace()
+	if comment := l.skiptrivia(); comment != nil {
+		tok = token{ttype: ILLEGAL, literal: comment.Text, err: &ParseError{
+			Code:    ErrUnterminated,
+			Message: "unterminated block comment",
+			Hint:    "did you forget a closing `*/`?",
+		}}
+		return l.located(tok, comment.start)
+	}
+	start := l.position()
 
 	switch l.char {
 	case '=':
@@ -40,6 +73,8 @@ func (l *lexer) next() token {
 		tok = newtoken(ASSIGN, l.char)
 	case ';':
 		tok = newtoken(SEMICOLON, l.char)
+	case ':':
+		tok = newtoken(COLON, l.char)
 	case '(':
 		tok = newtoken(LPAREN, l.char)
 	case ')':
@@ -48,8 +83,14 @@ func (l *lexer) next() token {
 		tok = newtoken(LBRACE, l.char)
 	case '}':
 		tok = newtoken(RBRACE, l.char)
+	case '[':
+		tok = newtoken(LBRACKET, l.char)
+	case ']':
+		tok = newtoken(RBRACKET, l.char)
 	case ',':
 		tok = newtoken(COMMA, l.char)
+	case '"':
+		tok = l.readstring()
 	case '+':
 		tok = newtoken(PLUS, l.char)
 	case '-':
@@ -77,40 +118,257 @@ func (l *lexer) next() token {
 		if isletter(l.char) {
 			tok.literal = l.readidentifier()
 			tok.ttype = lookupIdent(tok.literal)
-			return tok
+			return l.located(tok, start)
 		} else if isdigit(l.char) {
-			tok.literal = l.readnumber()
-			tok.ttype = INT
-			return tok
+			tok.literal, tok.ttype = l.readnumber()
+			if isletter(l.char) || isunicodedigit(l.char) {
+				// a number running into a name, like 1e, 0x1p3 or 2else, is
+				// one broken literal rather than a number and a name.
+				tok.literal += l.readidentifier()
+				tok.ttype = ILLEGAL
+				tok.err = &ParseError{
+					Code:    ErrMalformedNumber,
+					Message: fmt.Sprintf("malformed number %q", tok.literal),
+				}
+			}
+			return l.located(tok, start)
+		} else if l.char == utf8.RuneError && l.readPos-l.pos == 1 {
+			tok = token{ttype: ILLEGAL, literal: l.input[l.pos:l.readPos], err: &ParseError{
+				Code:    ErrIllegalToken,
+				Message: "invalid UTF-8 encoding",
+			}}
+			break
 		}
 		tok = newtoken(ILLEGAL, l.char)
 	}
 
 	l.read()
+	return l.located(tok, start)
+}
+
+func (l *lexer) position() Position {
+	return Position{Line: l.line, Column: l.col, Offset: l.pos}
+}
+
+// located sets the span of tok and hands it the comments skipped on the
+// way to it.
+func (l *lexer) located(tok token, start Position) token {
+	tok.start = start
+	tok.end = l.position()
+	tok.comments = l.comments
+	l.comments = nil
 	return tok
 }
 
 func (l *lexer) readidentifier() string {
 	pos := l.pos
-	for isletter(l.char) {
+	for isletter(l.char) || isunicodedigit(l.char) {
 		l.read()
 	}
 	return l.input[pos:l.pos]
 }
 
-func (l *lexer) readnumber() string {
+// readstring reads a double quoted string literal and decodes its escape
+// sequences. Malformed literals come back as an ILLEGAL token carrying
+// the error, the lexer still moves past the whole literal.
+func (l *lexer) readstring() token {
 	pos := l.pos
-	for isdigit(l.char) {
+	var out strings.Builder
+	var err *ParseError
+
+	for {
 		l.read()
+
+		switch l.char {
+		case 0:
+			if l.pos >= len(l.input) {
+				return token{ttype: ILLEGAL, literal: l.input[pos:], err: &ParseError{
+					Code:    ErrUnterminated,
+					Message: "unterminated string literal",
+					Hint:    "did you forget a closing `\"`?",
+				}}
+			}
+			out.WriteString(l.input[l.pos:l.readPos])
+		case '"':
+			if err != nil {
+				return token{ttype: ILLEGAL, literal: l.input[pos : l.pos+1], err: err}
+			}
+			return token{ttype: STRING, literal: out.String()}
+		case '\\':
+			l.read()
+			if e := l.readescape(&out); e != "" && err == nil {
+				err = &ParseError{Code: ErrInvalidEscape, Message: e}
+			}
+		default:
+			out.WriteString(l.input[l.pos:l.readPos])
+		}
 	}
-	return l.input[pos:l.pos]
 }
 
-func (l *lexer) peek() byte {
+// readescape decodes the escape sequence following a backslash into out
+// and returns a description of the problem if it is not a valid one.
+func (l *lexer) readescape(out *strings.Builder) string {
+	switch l.char {
+	case 'n':
+		out.WriteByte('\n')
+	case 't':
+		out.WriteByte('\t')
+	case '"':
+		out.WriteByte('"')
+	case '\\':
+		out.WriteByte('\\')
+	case 'u':
+		if l.peek() != '{' {
+			return "expected { after \\u"
+		}
+		l.read()
+
+		start := l.readPos
+		for l.peek() != '}' && l.peek() != '"' && l.peek() != 0 {
+			l.read()
+		}
+		digits := l.input[start:l.readPos]
+
+		if l.peek() != '}' {
+			return "unterminated unicode escape \\u{" + digits
+		}
+		l.read()
+
+		value, err := strconv.ParseUint(digits, 16, 32)
+		if err != nil || len(digits) > 6 || !utf8.ValidRune(rune(value)) {
+			return fmt.Sprintf("invalid unicode escape \\u{%s}", digits)
+		}
+		out.WriteRune(rune(value))
+	case 0:
+		if l.pos >= len(l.input) {
+			return "unterminated escape sequence"
+		}
+		return fmt.Sprintf("unknown escape sequence \\%c", l.char)
+	default:
+		if l.char == utf8.RuneError && l.readPos-l.pos == 1 {
+			return "invalid UTF-8 encoding"
+		}
+		return fmt.Sprintf("unknown escape sequence \\%c", l.char)
+	}
+	return ""
+}
+
+// readnumber reads integer and float literals. It only finds where the
+// literal ends, checking digits and separators is left to strconv when
+// the parser converts the literal.
+func (l *lexer) readnumber() (string, TokenType) {
+	pos := l.pos
+
+	if l.char == '0' && strings.ContainsRune("xXoObB", l.peek()) {
+		l.read()
+		l.read()
+		for ishexdigit(l.char) || l.char == '_' {
+			l.read()
+		}
+		return l.input[pos:l.pos], INT
+	}
+
+	var ttype TokenType = INT
+	l.readdigits()
+
+	if l.char == '.' && isdigit(l.peek()) {
+		ttype = FLOAT
+		l.read()
+		l.readdigits()
+	}
+
+	if (l.char == 'e' || l.char == 'E') && (isdigit(l.peek()) || l.peek() == '+' || l.peek() == '-') {
+		ttype = FLOAT
+		l.read()
+		if l.char == '+' || l.char == '-' {
+			l.read()
+		}
+		l.readdigits()
+	}
+
+	return l.input[pos:l.pos], ttype
+}
+
+func (l *lexer) readdigits() {
+	for isdigit(l.char) || l.char == '_' {
+		l.read()
+	}
+}
+
+func (l *lexer) peek() rune {
 	if l.readPos >= len(l.input) {
 		return 0
 	}
-	return l.input[l.readPos]
+	char, _ := utf8.DecodeRuneInString(l.input[l.readPos:])
+	return char
+}
+
+// skiptrivia skips whitespace and comments, keeping the comments for the
+// next token. An unterminated block comment is returned so it can be
+// reported, it swallows the rest of the input.
+func (l *lexer) skiptrivia() *Comment {
+	for {
+		l.skipspace()
+
+		if l.char != '/' {
+			return nil
+		}
+
+		switch l.peek() {
+		case '/':
+			l.comments = append(l.comments, l.readlinecomment())
+		case '*':
+			comment, ok := l.readblockcomment()
+			if !ok {
+				return comment
+			}
+			l.comments = append(l.comments, comment)
+		default:
+			return nil
+		}
+	}
+}
+
+func (l *lexer) readlinecomment() *Comment {
+	start := l.position()
+
+	for l.char != '\n' && !l.ateof() {
+		l.read()
+	}
+
+	text := strings.TrimRight(l.input[start.Offset:l.pos], "\r")
+	return &Comment{span: span{start: start, end: l.position()}, Text: text}
+}
+
+// readblockcomment reads a /* */ comment, block comments nest so a block
+// can be commented out even if it already contains one.
+func (l *lexer) readblockcomment() (*Comment, bool) {
+	start := l.position()
+	depth := 0
+
+	for !l.ateof() {
+		switch {
+		case l.char == '/' && l.peek() == '*':
+			depth++
+			l.read()
+		case l.char == '*' && l.peek() == '/':
+			depth--
+			l.read()
+		}
+		l.read()
+
+		if depth == 0 {
+			comment := &Comment{span: span{start: start, end: l.position()}, Text: l.input[start.Offset:l.pos]}
+			return comment, true
+		}
+	}
+
+	comment := &Comment{span: span{start: start, end: l.position()}, Text: l.input[start.Offset:]}
+	return comment, false
+}
+
+func (l *lexer) ateof() bool {
+	return l.char == 0 && l.pos >= len(l.input)
 }
 
 func (l *lexer) skipspace() {
@@ -119,10 +377,26 @@ func (l *lexer) skipspace() {
 	}
 }
 
-func isletter(char byte) bool {
-	return 'a' <= char && char <= 'z' || 'A' <= char && char <= 'Z' || char == '_'
+// isletter follows the Go spec, identifiers start with a Unicode letter
+// or an underscore and may continue with letters and Unicode digits.
+func isletter(char rune) bool {
+	if char < utf8.RuneSelf {
+		return 'a' <= char && char <= 'z' || 'A' <= char && char <= 'Z' || char == '_'
+	}
+	return unicode.IsLetter(char)
 }
 
-func isdigit(char byte) bool {
+func isunicodedigit(char rune) bool {
+	if char < utf8.RuneSelf {
+		return isdigit(char)
+	}
+	return unicode.IsDigit(char)
+}
+
+func isdigit(char rune) bool {
 	return '0' <= char && char <= '9'
 }
+
+func ishexdigit(char rune) bool {
+	return isdigit(char) || 'a' <= char && char <= 'f' || 'A' <= char && char <= 'F'
+}
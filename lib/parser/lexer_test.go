@@ -13,7 +13,7 @@ let add = fn(x, y){
 };
 
 let result = add(five, ten);
-!-/*5;
+!-/ *5;
 5 < 10 > 5;
 if (5 < 10) {
 	return true;
@@ -22,10 +22,11 @@ if (5 < 10) {
 }
 10 == 10;
 10 != 9;
+[1, 2][0:1];
 `
 
 	tests := []struct {
-		expectedType    tokenType
+		expectedType    TokenType
 		expectedLiteral string
 	}{
 		{LET, "let"},
@@ -101,6 +102,17 @@ if (5 < 10) {
 		{NOTEQ, "!="},
 		{INT, "9"},
 		{SEMICOLON, ";"},
+		{LBRACKET, "["},
+		{INT, "1"},
+		{COMMA, ","},
+		{INT, "2"},
+		{RBRACKET, "]"},
+		{LBRACKET, "["},
+		{INT, "0"},
+		{COLON, ":"},
+		{INT, "1"},
+		{RBRACKET, "]"},
+		{SEMICOLON, ";"},
 		{EOF, ""},
 	}
 
@@ -118,3 +130,347 @@ if (5 < 10) {
 		}
 	}
 }
+
+func TestTokenPositions(t *testing.T) {
+	input := "let x = 10;\n  fn(a) {\n\ta == b }"
+
+	tests := []struct {
+		expectedType TokenType
+		start        Position
+		end          Position
+	}{
+		{LET, Position{1, 1, 0}, Position{1, 4, 3}},
+		{IDENT, Position{1, 5, 4}, Position{1, 6, 5}},
+		{ASSIGN, Position{1, 7, 6}, Position{1, 8, 7}},
+		{INT, Position{1, 9, 8}, Position{1, 11, 10}},
+		{SEMICOLON, Position{1, 11, 10}, Position{1, 12, 11}},
+		{FUNCTION, Position{2, 3, 14}, Position{2, 5, 16}},
+		{LPAREN, Position{2, 5, 16}, Position{2, 6, 17}},
+		{IDENT, Position{2, 6, 17}, Position{2, 7, 18}},
+		{RPAREN, Position{2, 7, 18}, Position{2, 8, 19}},
+		{LBRACE, Position{2, 9, 20}, Position{2, 10, 21}},
+		{IDENT, Position{3, 2, 23}, Position{3, 3, 24}},
+		{EQ, Position{3, 4, 25}, Position{3, 6, 27}},
+		{IDENT, Position{3, 7, 28}, Position{3, 8, 29}},
+		{RBRACE, Position{3, 9, 30}, Position{3, 10, 31}},
+		{EOF, Position{3, 10, 31}, Position{3, 11, 32}},
+	}
+
+	l := newlexer(input)
+
+	for i, tt := range tests {
+		tok := l.next()
+
+		if tok.ttype != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.ttype)
+		}
+
+		if tok.start != tt.start {
+			t.Errorf("tests[%d] - start wrong. expected=%+v, got=%+v", i, tt.start, tok.start)
+		}
+
+		if tok.end != tt.end {
+			t.Errorf("tests[%d] - end wrong. expected=%+v, got=%+v", i, tt.end, tok.end)
+		}
+	}
+}
+
+func TestStringLiterals(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedType    TokenType
+		expectedLiteral string
+		expectedError   string
+	}{
+		{`"foobar"`, STRING, "foobar", ""},
+		{`"foo bar"`, STRING, "foo bar", ""},
+		{`""`, STRING, "", ""},
+		{`"a\nb\tc"`, STRING, "a\nb\tc", ""},
+		{`"say \"hi\""`, STRING, `say "hi"`, ""},
+		{`"back\\slash"`, STRING, `back\slash`, ""},
+		{`"\u{48}\u{e9}\u{1F600}"`, STRING, "Hé😀", ""},
+		{"\"two\nlines\"", STRING, "two\nlines", ""},
+		{`"open`, ILLEGAL, `"open`, "unterminated string literal"},
+		{`"bad \q escape"`, ILLEGAL, `"bad \q escape"`, `unknown escape sequence \q`},
+		{`"\u{zz}"`, ILLEGAL, `"\u{zz}"`, `invalid unicode escape \u{zz}`},
+		{`"\u{110000}"`, ILLEGAL, `"\u{110000}"`, `invalid unicode escape \u{110000}`},
+		{`"\u{41"`, ILLEGAL, `"\u{41"`, `unterminated unicode escape \u{41`},
+		{`"\u41"`, ILLEGAL, `"\u41"`, `expected { after \u`},
+		{`"trailing\`, ILLEGAL, `"trailing\`, "unterminated string literal"},
+	}
+
+	for i, tt := range tests {
+		l := newlexer(tt.input)
+		tok := l.next()
+
+		if tok.ttype != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.ttype)
+		}
+
+		if tok.literal != tt.expectedLiteral {
+			t.Errorf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.literal)
+		}
+
+		if tt.expectedError == "" && tok.err != nil {
+			t.Errorf("tests[%d] - unexpected error %q", i, tok.err.Message)
+		}
+
+		if tt.expectedError != "" && (tok.err == nil || tok.err.Message != tt.expectedError) {
+			t.Errorf("tests[%d] - error wrong. expected=%q, got=%+v", i, tt.expectedError, tok.err)
+		}
+
+		if next := l.next(); next.ttype != EOF {
+			t.Errorf("tests[%d] - literal not fully consumed, next token is %q", i, next.ttype)
+		}
+	}
+}
+
+func TestUnicodeInput(t *testing.T) {
+	input := "let größe = \"ça va 😀\";\nπ2 + x١ + _名前;"
+
+	tests := []struct {
+		expectedType    TokenType
+		expectedLiteral string
+		start           Position
+		end             Position
+	}{
+		{LET, "let", Position{1, 1, 0}, Position{1, 4, 3}},
+		{IDENT, "größe", Position{1, 5, 4}, Position{1, 10, 11}},
+		{ASSIGN, "=", Position{1, 11, 12}, Position{1, 12, 13}},
+		{STRING, "ça va 😀", Position{1, 13, 14}, Position{1, 22, 27}},
+		{SEMICOLON, ";", Position{1, 22, 27}, Position{1, 23, 28}},
+		{IDENT, "π2", Position{2, 1, 29}, Position{2, 3, 32}},
+		{PLUS, "+", Position{2, 4, 33}, Position{2, 5, 34}},
+		{IDENT, "x١", Position{2, 6, 35}, Position{2, 8, 38}},
+		{PLUS, "+", Position{2, 9, 39}, Position{2, 10, 40}},
+		{IDENT, "_名前", Position{2, 11, 41}, Position{2, 14, 48}},
+		{SEMICOLON, ";", Position{2, 14, 48}, Position{2, 15, 49}},
+		{EOF, "", Position{2, 15, 49}, Position{2, 16, 50}},
+	}
+
+	l := newlexer(input)
+
+	for i, tt := range tests {
+		tok := l.next()
+
+		if tok.ttype != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.ttype)
+		}
+
+		if tok.literal != tt.expectedLiteral {
+			t.Errorf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.literal)
+		}
+
+		if tok.start != tt.start || tok.end != tt.end {
+			t.Errorf("tests[%d] - span wrong. expected=%+v-%+v, got=%+v-%+v", i, tt.start, tt.end, tok.start, tok.end)
+		}
+	}
+}
+
+func TestUnicodeIllegal(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedLiteral string
+		expectedError   string
+	}{
+		{"€", "€", ""},
+		{"１", "１", ""},
+		{"\xff", "\xff", "invalid UTF-8 encoding"},
+		{"\"a\\\xff\"", "\"a\\\xff\"", "invalid UTF-8 encoding"},
+	}
+
+	for i, tt := range tests {
+		l := newlexer(tt.input)
+		tok := l.next()
+
+		if tok.ttype != ILLEGAL {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=ILLEGAL, got=%q", i, tok.ttype)
+		}
+
+		if tok.literal != tt.expectedLiteral {
+			t.Errorf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.literal)
+		}
+
+		if tt.expectedError != "" && (tok.err == nil || tok.err.Message != tt.expectedError) {
+			t.Errorf("tests[%d] - error wrong. expected=%q, got=%+v", i, tt.expectedError, tok.err)
+		}
+
+		if next := l.next(); next.ttype != EOF {
+			t.Errorf("tests[%d] - input not fully consumed, next token is %q", i, next.ttype)
+		}
+	}
+}
+
+func TestInvalidUTF8InString(t *testing.T) {
+	l := newlexer("\"a\xffb\"")
+	tok := l.next()
+
+	if tok.ttype != STRING || tok.literal != "a\xffb" {
+		t.Errorf("raw bytes in strings not preserved. got=%q %q", tok.ttype, tok.literal)
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// leading comment
+let x = 10; // trailing
+/* block /* nested */ still comment */ x / 2;
+/**/ 8 */* not a divide */ 2 //`
+
+	tests := []struct {
+		expectedType    TokenType
+		expectedLiteral string
+		comments        []string
+	}{
+		{LET, "let", []string{"// leading comment"}},
+		{IDENT, "x", nil},
+		{ASSIGN, "=", nil},
+		{INT, "10", nil},
+		{SEMICOLON, ";", nil},
+		{IDENT, "x", []string{"// trailing", "/* block /* nested */ still comment */"}},
+		{SLASH, "/", nil},
+		{INT, "2", nil},
+		{SEMICOLON, ";", nil},
+		{INT, "8", []string{"/**/"}},
+		{ASTERISK, "*", nil},
+		{INT, "2", []string{"/* not a divide */"}},
+		{EOF, "", []string{"//"}},
+	}
+
+	l := newlexer(input)
+
+	for i, tt := range tests {
+		tok := l.next()
+
+		if tok.ttype != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.ttype)
+		}
+
+		if tok.literal != tt.expectedLiteral {
+			t.Errorf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.literal)
+		}
+
+		if len(tok.comments) != len(tt.comments) {
+			t.Errorf("tests[%d] - wrong number of comments. expected=%d, got=%d", i, len(tt.comments), len(tok.comments))
+			continue
+		}
+
+		for j, text := range tt.comments {
+			if tok.comments[j].Text != text {
+				t.Errorf("tests[%d] - comment[%d] wrong. expected=%q, got=%q", i, j, text, tok.comments[j].Text)
+			}
+		}
+	}
+}
+
+func TestUnterminatedBlockComment(t *testing.T) {
+	l := newlexer("1 /* open /* nested */ \n")
+
+	if tok := l.next(); tok.ttype != INT {
+		t.Fatalf("first token is not INT. got=%q", tok.ttype)
+	}
+
+	tok := l.next()
+	if tok.ttype != ILLEGAL || tok.err == nil || tok.err.Message != "unterminated block comment" {
+		t.Fatalf("unterminated comment not reported. got=%q %+v", tok.ttype, tok.err)
+	}
+
+	if tok.start.String() != "1:3" {
+		t.Errorf("error does not start at the comment. got=%s", tok.start)
+	}
+
+	if next := l.next(); next.ttype != EOF {
+		t.Errorf("comment did not swallow the input, next token is %q", next.ttype)
+	}
+}
+
+func TestNumberLiterals(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedType    TokenType
+		expectedLiteral string
+	}{
+		{"42", INT, "42"},
+		{"1_000_000", INT, "1_000_000"},
+		{"0xff", INT, "0xff"},
+		{"0XFF_FF", INT, "0XFF_FF"},
+		{"0o17", INT, "0o17"},
+		{"0b1010", INT, "0b1010"},
+		{"3.14", FLOAT, "3.14"},
+		{"1e-9", FLOAT, "1e-9"},
+		{"2.5E+10", FLOAT, "2.5E+10"},
+		{"6e23", FLOAT, "6e23"},
+		{"1_000.000_1", FLOAT, "1_000.000_1"},
+	}
+
+	for i, tt := range tests {
+		l := newlexer(tt.input)
+		tok := l.next()
+
+		if tok.ttype != tt.expectedType {
+			t.Errorf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.ttype)
+		}
+
+		if tok.literal != tt.expectedLiteral {
+			t.Errorf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.literal)
+		}
+
+		if next := l.next(); next.ttype != EOF {
+			t.Errorf("tests[%d] - literal not fully consumed, next token is %q", i, next.ttype)
+		}
+	}
+}
+
+func TestMalformedNumbers(t *testing.T) {
+	tests := []struct {
+		input    string
+		literal  string
+		end      string
+		expected string
+	}{
+		{"1e", "1e", "1:3", `malformed number "1e"`},
+		{"1.5e", "1.5e", "1:5", `malformed number "1.5e"`},
+		{"0x1p3", "0x1p3", "1:6", `malformed number "0x1p3"`},
+		{"2else", "2else", "1:6", `malformed number "2else"`},
+		{"10px + 1", "10px", "1:5", `malformed number "10px"`},
+	}
+
+	for _, tt := range tests {
+		tok := newlexer(tt.input).next()
+
+		if tok.ttype != ILLEGAL || tok.literal != tt.literal {
+			t.Errorf("%q - wrong token. expected=ILLEGAL %q, got=%s %q", tt.input, tt.literal, tok.ttype, tok.literal)
+			continue
+		}
+
+		if tok.err == nil || tok.err.Code != ErrMalformedNumber || tok.err.Message != tt.expected {
+			t.Errorf("%q - wrong error. expected=%q, got=%+v", tt.input, tt.expected, tok.err)
+		}
+
+		if tok.start.String() != "1:1" || tok.end.String() != tt.end {
+			t.Errorf("%q - wrong span. expected=1:1-%s, got=%s-%s", tt.input, tt.end, tok.start, tok.end)
+		}
+	}
+}
+
+func TestNumberBoundaries(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []TokenType
+	}{
+		{"1.foo", []TokenType{INT, ILLEGAL, IDENT}},
+		{"2else", []TokenType{ILLEGAL, EOF}},
+		{"1e;", []TokenType{ILLEGAL, SEMICOLON}},
+		{"3.5.1", []TokenType{FLOAT, ILLEGAL, INT}},
+		{"10/2.0", []TokenType{INT, SLASH, FLOAT}},
+	}
+
+	for _, tt := range tests {
+		l := newlexer(tt.input)
+
+		for i, expected := range tt.expected {
+			if tok := l.next(); tok.ttype != expected {
+				t.Errorf("%q tokens[%d] - tokentype wrong. expected=%q, got=%q", tt.input, i, expected, tok.ttype)
+			}
+		}
+	}
+}
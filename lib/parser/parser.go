@@ -1,7 +1,9 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
+	"math/big"
 	"strconv"
 )
 
@@ -14,9 +16,10 @@ const (
 	PRODUCT
 	PREFIX
 	CALL
+	INDEX
 )
 
-var precedences = map[tokenType]int{
+var precedences = map[TokenType]int{
 	EQ:       EQUALS,
 	NOTEQ:    EQUALS,
 	LT:       LESSGREATER,
@@ -25,41 +28,58 @@ var precedences = map[tokenType]int{
 	MINUS:    SUM,
 	ASTERISK: PRODUCT,
 	SLASH:    PRODUCT,
+	LPAREN:   CALL,
+	LBRACKET: INDEX,
 }
 
 type (
-	prefixparse func() expression
-	infixparse  func(expression) expression
+	prefixparse func() Expression
+	infixparse  func(Expression) Expression
 )
 
 type Parser struct {
 	lex    *lexer
-	errors []string
+	errors ErrorList
 
+	prevtok token
 	curtok  token
 	nexttok token
+	pending []token
 
-	prefixparsefns map[tokenType]prefixparse
-	infixparsefns  map[tokenType]infixparse
+	// depth is the number of braces opened before curtok and not closed
+	// yet, error recovery uses it to find the end of a statement.
+	depth int
+
+	comments []*Comment
+
+	prefixparsefns map[TokenType]prefixparse
+	infixparsefns  map[TokenType]infixparse
 }
 
 func NewParser(input string) *Parser {
 	l := newlexer(input)
-	temp := Parser{lex: l, errors: []string{}}
+	temp := Parser{lex: l, errors: ErrorList{}}
 
-	temp.curtok = l.next()
-	temp.nexttok = l.next()
+	temp.curtok = temp.lextoken()
+	temp.nexttok = temp.lextoken()
 
-	temp.prefixparsefns = make(map[tokenType]prefixparse)
+	temp.prefixparsefns = make(map[TokenType]prefixparse)
 	temp.registerprefix(IDENT, temp.parseident)
 	temp.registerprefix(INT, temp.parseintliteral)
+	temp.registerprefix(FLOAT, temp.parsefloatliteral)
+	temp.registerprefix(STRING, temp.parsestringliteral)
+	temp.registerprefix(ILLEGAL, temp.parseillegal)
 	temp.registerprefix(MINUS, temp.parseprefixexpr)
 	temp.registerprefix(BANG, temp.parseprefixexpr)
 	temp.registerprefix(TRUE, temp.parseboolexpr)
 	temp.registerprefix(FALSE, temp.parseboolexpr)
 	temp.registerprefix(LPAREN, temp.parsegroupedexpr)
+	temp.registerprefix(IF, temp.parseifexpr)
+	temp.registerprefix(FUNCTION, temp.parsefnliteral)
+	temp.registerprefix(LBRACKET, temp.parsearrayliteral)
+	temp.registerprefix(LBRACE, temp.parsehashliteral)
 
-	temp.infixparsefns = make(map[tokenType]infixparse)
+	temp.infixparsefns = make(map[TokenType]infixparse)
 	temp.registerinfix(PLUS, temp.parseinfixexpr)
 	temp.registerinfix(MINUS, temp.parseinfixexpr)
 	temp.registerinfix(ASTERISK, temp.parseinfixexpr)
@@ -68,97 +88,234 @@ func NewParser(input string) *Parser {
 	temp.registerinfix(GT, temp.parseinfixexpr)
 	temp.registerinfix(EQ, temp.parseinfixexpr)
 	temp.registerinfix(NOTEQ, temp.parseinfixexpr)
+	temp.registerinfix(LPAREN, temp.parsecallexpr)
+	temp.registerinfix(LBRACKET, temp.parseindexexpr)
 
 	return &temp
 }
 
-func (p *Parser) Errors() []string {
+func (p *Parser) Errors() ErrorList {
 	return p.errors
 }
 
-func (p *Parser) Parse() *program {
-	prog := &program{}
-	prog.statements = []statement{}
+func (p *Parser) Parse() *Program {
+	prog := &Program{}
+	prog.Statements = []Statement{}
+	start := p.curtok.start
 
 	for p.curtok.ttype != EOF {
 		stmt := p.parsestatement()
 
 		if stmt != nil {
-			prog.statements = append(prog.statements, stmt)
+			prog.Statements = append(prog.Statements, stmt)
 		}
 		p.next()
 	}
+
+	prog.span = span{start: start, end: p.curtok.start}
+	prog.Comments = p.comments
 	return prog
 }
 
 func (p *Parser) next() {
+	p.depth += braces(p.curtok)
+	if p.depth < 0 {
+		p.depth = 0
+	}
+
+	p.prevtok = p.curtok
 	p.curtok = p.nexttok
-	p.nexttok = p.lex.next()
+
+	if len(p.pending) > 0 {
+		p.nexttok = p.pending[len(p.pending)-1]
+		p.pending = p.pending[:len(p.pending)-1]
+		return
+	}
+	p.nexttok = p.lextoken()
+}
+
+// lextoken reads the next token from the lexer and keeps the comments
+// in front of it for the program.
+func (p *Parser) lextoken() token {
+	tok := p.lex.next()
+	p.comments = append(p.comments, tok.comments...)
+	return tok
+}
+
+// backup undoes the last call to next, only one step is remembered.
+func (p *Parser) backup() {
+	p.pending = append(p.pending, p.nexttok)
+	p.nexttok = p.curtok
+	p.curtok = p.prevtok
+	p.depth -= braces(p.curtok)
+}
+
+// braces is how tok changes the brace depth.
+func braces(tok token) int {
+	switch tok.ttype {
+	case LBRACE:
+		return 1
+	case RBRACE:
+		return -1
+	}
+	return 0
 }
 
-func (p *Parser) parsestatement() statement {
+func (p *Parser) parsestatement() Statement {
+	start := p.curtok
+	depth := p.depth
+
 	switch p.curtok.ttype {
 	case LET:
-		return p.parselet()
+		if stmt := p.parselet(); stmt != nil {
+			return stmt
+		}
 	case RETURN:
-		return p.parsereturn()
+		if stmt := p.parsereturn(); stmt != nil {
+			return stmt
+		}
 	default:
-		return p.parseexprstatement()
+		if stmt := p.parseexprstatement(); stmt != nil {
+			return stmt
+		}
+	}
+
+	return p.badstatement(start, depth)
+}
+
+// badstatement is called once a statement starting at start failed to
+// parse, the error is already reported. It skips the rest of the
+// statement so parsing can resume at the next one.
+func (p *Parser) badstatement(start token, depth int) *BadStatement {
+	// only a stray brace at the top level starts a statement with }, there
+	// is no block to hand it back to so it is skipped on its own, along
+	// with a semicolon right after it.
+	if start.ttype != RBRACE {
+		p.synchronize(depth)
+	} else if p.nexttokis(SEMICOLON) {
+		p.next()
+	}
+	return &BadStatement{span: p.spanfrom(start.start), tok: start}
+}
+
+// synchronize advances to the end of the broken statement, that is the
+// next semicolon or the token before a let, return or a closing brace of
+// the enclosing block, which is at the given depth. Braces opened by the
+// statement are skipped as a whole so a broken if, fn or hash doesn't
+// leave its body behind.
+func (p *Parser) synchronize(depth int) {
+	for !p.curtokis(EOF) {
+		if p.curtokis(RBRACE) && p.depth == depth {
+			p.backup()
+			return
+		}
+
+		if p.depth+braces(p.curtok) <= depth {
+			if p.curtokis(SEMICOLON) {
+				return
+			}
+
+			switch p.nexttok.ttype {
+			case LET, RETURN, RBRACE, EOF:
+				return
+			}
+		}
+
+		p.next()
 	}
 }
 
-func (p *Parser) parselet() *letstatement {
-	stmt := &letstatement{tok: p.curtok}
+func (p *Parser) parselet() *LetStatement {
+	stmt := &LetStatement{tok: p.curtok}
 
 	if !p.expect(IDENT) {
 		return nil
 	}
 
-	stmt.name = &identifier{tok: p.curtok, value: p.curtok.literal}
+	stmt.Name = &Identifier{span: p.tokspan(), tok: p.curtok, Value: p.curtok.literal}
 
 	if !p.expect(ASSIGN) {
 		return nil
 	}
 
-	for !p.curtokis(SEMICOLON) {
+	if p.nexttokis(EOF) {
+		p.unexpectedeof("let", p.nexttok)
+		return nil
+	}
+
+	p.next()
+	stmt.Value = p.parseexpr(LOWEST)
+	if stmt.Value == nil {
+		return nil
+	}
+
+	if p.nexttokis(SEMICOLON) {
 		p.next()
 	}
+
+	stmt.span = p.spanfrom(stmt.tok.start)
 	return stmt
 }
 
-func (p *Parser) parsereturn() *returnstatement {
-	stmt := &returnstatement{tok: p.curtok}
+func (p *Parser) parsereturn() *ReturnStatement {
+	stmt := &ReturnStatement{tok: p.curtok}
+
+	if p.nexttokis(SEMICOLON) {
+		p.next()
+		stmt.span = p.spanfrom(stmt.tok.start)
+		return stmt
+	}
+
+	if p.nexttokis(RBRACE) || p.nexttokis(EOF) {
+		stmt.span = p.tokspan()
+		return stmt
+	}
+
 	p.next()
-	for !p.curtokis(SEMICOLON) {
+	stmt.Value = p.parseexpr(LOWEST)
+	if stmt.Value == nil {
+		return nil
+	}
+
+	if p.nexttokis(SEMICOLON) {
 		p.next()
 	}
+
+	stmt.span = p.spanfrom(stmt.tok.start)
 	return stmt
 }
 
-func (p *Parser) parseexprstatement() *expressionstatement {
-	stmt := &expressionstatement{tok: p.curtok}
-	stmt.expr = p.parseexpr(LOWEST)
+func (p *Parser) parseexprstatement() *ExpressionStatement {
+	stmt := &ExpressionStatement{tok: p.curtok}
+	stmt.Expr = p.parseexpr(LOWEST)
+	if stmt.Expr == nil {
+		return nil
+	}
 
 	if p.nexttokis(SEMICOLON) {
 		p.next()
 	}
 
+	stmt.span = p.spanfrom(stmt.tok.start)
 	return stmt
 }
 
-func (p *Parser) parseident() expression {
-	return &identifier{tok: p.curtok, value: p.curtok.literal}
+func (p *Parser) parseident() Expression {
+	return &Identifier{span: p.tokspan(), tok: p.curtok, Value: p.curtok.literal}
 }
 
-func (p *Parser) parseexpr(precedence int) expression {
+func (p *Parser) parseexpr(precedence int) Expression {
 	prefix := p.prefixparsefns[p.curtok.ttype]
 
 	if prefix == nil {
-		p.noprefixfound(p.curtok.ttype)
+		p.noprefixfound(p.curtok)
 		return nil
 	}
 
 	left := prefix()
+	if left == nil {
+		return nil
+	}
 
 	for !p.nexttokis(SEMICOLON) && precedence < p.peekprecedence() {
 		infix := p.infixparsefns[p.nexttok.ttype]
@@ -167,72 +324,438 @@ func (p *Parser) parseexpr(precedence int) expression {
 		}
 		p.next()
 		left = infix(left)
+		if left == nil {
+			return nil
+		}
 	}
 
 	return left
 }
 
-func (p *Parser) parseintliteral() expression {
-	lit := &intliteral{tok: p.curtok}
+func (p *Parser) parseintliteral() Expression {
+	lit := &IntLiteral{span: p.tokspan(), tok: p.curtok}
+
+	// strconv would read these as legacy octal, octal is written 0o17.
+	if literal := p.curtok.literal; len(literal) > 1 && literal[0] == '0' && (isdigit(rune(literal[1])) || literal[1] == '_') {
+		p.report(&ParseError{
+			Code:    ErrInvalidInteger,
+			Actual:  p.curtok.ttype,
+			Message: fmt.Sprintf("decimal integer %q can't start with 0, write octal with 0o", literal),
+		}, p.curtok)
+		return nil
+	}
+
 	value, err := strconv.ParseInt(p.curtok.literal, 0, 64)
 
+	if errors.Is(err, strconv.ErrRange) {
+		if n, ok := new(big.Int).SetString(p.curtok.literal, 0); ok {
+			lit.Big = n
+			return lit
+		}
+	}
+
+	if err != nil {
+		p.report(&ParseError{
+			Code:    ErrInvalidInteger,
+			Actual:  p.curtok.ttype,
+			Message: fmt.Sprintf("could not parse %q as integer", p.curtok.literal),
+		}, p.curtok)
+		return nil
+	}
+
+	lit.Value = value
+	return lit
+}
+
+func (p *Parser) parsefloatliteral() Expression {
+	lit := &FloatLiteral{span: p.tokspan(), tok: p.curtok}
+	value, err := strconv.ParseFloat(p.curtok.literal, 64)
+
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as integer", p.curtok.literal)
-		p.errors = append(p.errors, msg)
+		p.report(&ParseError{
+			Code:    ErrInvalidFloat,
+			Actual:  p.curtok.ttype,
+			Message: fmt.Sprintf("could not parse %q as float", p.curtok.literal),
+		}, p.curtok)
 		return nil
 	}
 
-	lit.value = value
+	lit.Value = value
 	return lit
 }
 
-func (p *Parser) parseprefixexpr() expression {
-	expr := &prefixexpr{
+func (p *Parser) parsestringliteral() Expression {
+	return &StringLiteral{span: p.tokspan(), tok: p.curtok, Value: p.curtok.literal}
+}
+
+// parseillegal reports tokens the lexer could not make sense of, using
+// the lexer's own error when it left one.
+func (p *Parser) parseillegal() Expression {
+	if p.curtok.err != nil {
+		p.report(p.curtok.err, p.curtok)
+		return nil
+	}
+
+	p.report(&ParseError{
+		Code:    ErrIllegalToken,
+		Actual:  ILLEGAL,
+		Message: fmt.Sprintf("illegal character %q", p.curtok.literal),
+	}, p.curtok)
+	return nil
+}
+
+func (p *Parser) parseprefixexpr() Expression {
+	expr := &PrefixExpr{
 		tok:      p.curtok,
-		operator: p.curtok.literal,
+		Operator: p.curtok.literal,
 	}
 
 	p.next()
-	expr.right = p.parseexpr(PREFIX)
+	expr.Right = p.parseexpr(PREFIX)
+	if expr.Right == nil {
+		return nil
+	}
+	expr.span = p.spanfrom(expr.tok.start)
 	return expr
 }
 
-func (p *Parser) parseinfixexpr(l expression) expression {
-	expr := &infixexpr{
+func (p *Parser) parseinfixexpr(l Expression) Expression {
+	expr := &InfixExpr{
 		tok:      p.curtok,
-		operator: p.curtok.literal,
-		left:     l,
+		Operator: p.curtok.literal,
+		Left:     l,
 	}
 	precedence := p.curprecedence()
 	p.next()
-	expr.right = p.parseexpr(precedence)
+	expr.Right = p.parseexpr(precedence)
+	if expr.Right == nil {
+		return nil
+	}
+	expr.span = p.spanfrom(startof(l, expr.tok))
 	return expr
 }
 
-func (p *Parser) parseboolexpr() expression {
-	return &boolexpr{tok: p.curtok, value: p.curtokis(TRUE)}
+func (p *Parser) parseboolexpr() Expression {
+	return &BoolExpr{span: p.tokspan(), tok: p.curtok, Value: p.curtokis(TRUE)}
 }
 
-func (p *Parser) parsegroupedexpr() expression {
+func (p *Parser) parsegroupedexpr() Expression {
 	p.next()
 	expr := p.parseexpr(LOWEST)
+	if expr == nil {
+		return nil
+	}
+
+	if !p.expectclosing(RPAREN) {
+		return nil
+	}
+
+	return expr
+}
+
+func (p *Parser) parseifexpr() Expression {
+	expr := &IfExpr{tok: p.curtok}
+
+	if !p.expect(LPAREN) {
+		return nil
+	}
+
+	p.next()
+	expr.Condition = p.parseexpr(LOWEST)
+	if expr.Condition == nil {
+		return nil
+	}
+
+	if !p.expectclosing(RPAREN) {
+		return nil
+	}
+
+	if !p.expect(LBRACE) {
+		return nil
+	}
+
+	expr.Consequence = p.parseblockstatement()
+	if expr.Consequence == nil {
+		return nil
+	}
+
+	if !p.nexttokis(ELSE) {
+		expr.span = p.spanfrom(expr.tok.start)
+		return expr
+	}
+
+	p.next()
+
+	if p.nexttokis(IF) {
+		p.next()
+		tok := p.curtok
+		chained := p.parseifexpr()
+		if chained == nil {
+			return nil
+		}
+		stmt := &ExpressionStatement{span: p.spanfrom(tok.start), tok: tok, Expr: chained}
+		expr.Alternative = &BlockStatement{span: stmt.span, tok: tok, Statements: []Statement{stmt}}
+		expr.span = p.spanfrom(expr.tok.start)
+		return expr
+	}
+
+	if !p.expect(LBRACE) {
+		return nil
+	}
+
+	expr.Alternative = p.parseblockstatement()
+	if expr.Alternative == nil {
+		return nil
+	}
+
+	expr.span = p.spanfrom(expr.tok.start)
+	return expr
+}
+
+func (p *Parser) parseblockstatement() *BlockStatement {
+	block := &BlockStatement{tok: p.curtok}
+	block.Statements = []Statement{}
 
-	if !p.expect(RPAREN) {
+	p.next()
+
+	for !p.curtokis(RBRACE) {
+		if p.curtokis(EOF) {
+			p.unexpectedeof("block", p.curtok)
+			p.hint("did you forget a closing `}`?")
+			return nil
+		}
+
+		stmt := p.parsestatement()
+		if stmt != nil {
+			block.Statements = append(block.Statements, stmt)
+		}
+		p.next()
+	}
+
+	block.span = p.spanfrom(block.tok.start)
+	return block
+}
+
+func (p *Parser) parsefnliteral() Expression {
+	lit := &FnLiteral{tok: p.curtok}
+
+	if !p.expect(LPAREN) {
+		return nil
+	}
+
+	lit.Parameters = p.parsefnparameters()
+	if lit.Parameters == nil {
+		return nil
+	}
+
+	if !p.expect(LBRACE) {
+		return nil
+	}
+
+	lit.Body = p.parseblockstatement()
+	if lit.Body == nil {
+		return nil
+	}
+
+	lit.span = p.spanfrom(lit.tok.start)
+	return lit
+}
+
+func (p *Parser) parsefnparameters() []*Identifier {
+	identifiers := []*Identifier{}
+
+	if p.nexttokis(RPAREN) {
+		p.next()
+		return identifiers
+	}
+
+	if !p.expect(IDENT) {
+		return nil
+	}
+	identifiers = append(identifiers, &Identifier{span: p.tokspan(), tok: p.curtok, Value: p.curtok.literal})
+
+	for p.nexttokis(COMMA) {
+		p.next()
+		if !p.expect(IDENT) {
+			return nil
+		}
+		identifiers = append(identifiers, &Identifier{span: p.tokspan(), tok: p.curtok, Value: p.curtok.literal})
+	}
+
+	if !p.expectclosing(RPAREN) {
 		return nil
 	}
 
+	return identifiers
+}
+
+func (p *Parser) parsecallexpr(function Expression) Expression {
+	expr := &CallExpr{tok: p.curtok, Function: function}
+	expr.Arguments = p.parseexprlist(RPAREN)
+	if expr.Arguments == nil {
+		return nil
+	}
+	expr.span = p.spanfrom(startof(function, expr.tok))
 	return expr
 }
 
-func (p *Parser) curtokis(t tokenType) bool {
+// parseexprlist parses comma separated expressions up to the closing
+// token end, the current token is the one opening the list.
+func (p *Parser) parseexprlist(end TokenType) []Expression {
+	list := []Expression{}
+
+	if p.nexttokis(end) {
+		p.next()
+		return list
+	}
+
+	p.next()
+	expr := p.parseexpr(LOWEST)
+	if expr == nil {
+		return nil
+	}
+	list = append(list, expr)
+
+	for p.nexttokis(COMMA) {
+		p.next()
+		p.next()
+		expr := p.parseexpr(LOWEST)
+		if expr == nil {
+			return nil
+		}
+		list = append(list, expr)
+	}
+
+	if !p.expectclosing(end) {
+		return nil
+	}
+
+	return list
+}
+
+func (p *Parser) parsearrayliteral() Expression {
+	array := &ArrayLiteral{tok: p.curtok}
+	array.Elements = p.parseexprlist(RBRACKET)
+	if array.Elements == nil {
+		return nil
+	}
+	array.span = p.spanfrom(array.tok.start)
+	return array
+}
+
+// parsehashliteral parses {key: value, ...}. Blocks are only parsed
+// where the grammar asks for one, so a brace in expression position is
+// always a hash.
+func (p *Parser) parsehashliteral() Expression {
+	hash := &HashLiteral{tok: p.curtok, Pairs: []HashPair{}}
+
+	if p.nexttokis(RBRACE) {
+		p.next()
+		hash.span = p.spanfrom(hash.tok.start)
+		return hash
+	}
+
+	for {
+		p.next()
+		key := p.parseexpr(LOWEST)
+		if key == nil {
+			return nil
+		}
+
+		if !p.expect(COLON) {
+			return nil
+		}
+
+		p.next()
+		value := p.parseexpr(LOWEST)
+		if value == nil {
+			return nil
+		}
+
+		hash.Pairs = append(hash.Pairs, HashPair{Key: key, Value: value})
+
+		if !p.nexttokis(COMMA) {
+			break
+		}
+		p.next()
+	}
+
+	if !p.expectclosing(RBRACE) {
+		return nil
+	}
+
+	hash.span = p.spanfrom(hash.tok.start)
+	return hash
+}
+
+// parseindexexpr parses both left[index] and the slice left[low:high],
+// which one it is only shows once the colon is seen.
+func (p *Parser) parseindexexpr(left Expression) Expression {
+	tok := p.curtok
+	var low Expression
+
+	if !p.nexttokis(COLON) {
+		p.next()
+		low = p.parseexpr(LOWEST)
+		if low == nil {
+			return nil
+		}
+	}
+
+	if !p.nexttokis(COLON) {
+		if !p.expectclosing(RBRACKET) {
+			return nil
+		}
+		return &IndexExpr{span: p.spanfrom(startof(left, tok)), tok: tok, Left: left, Index: low}
+	}
+
+	p.next()
+	slice := &SliceExpr{tok: tok, Left: left, Low: low}
+
+	if !p.nexttokis(RBRACKET) {
+		p.next()
+		slice.High = p.parseexpr(LOWEST)
+		if slice.High == nil {
+			return nil
+		}
+	}
+
+	if !p.expectclosing(RBRACKET) {
+		return nil
+	}
+
+	slice.span = p.spanfrom(startof(left, tok))
+	return slice
+}
+
+// spanfrom returns a span from start to the end of the current token,
+// which is the last token consumed by the construct being parsed.
+func (p *Parser) spanfrom(start Position) span {
+	return span{start: start, end: p.curtok.end}
+}
+
+func (p *Parser) tokspan() span {
+	return span{start: p.curtok.start, end: p.curtok.end}
+}
+
+// startof returns where a node built around tok begins, falling back to
+// the token itself when the leading operand failed to parse.
+func startof(n Node, tok token) Position {
+	if n == nil {
+		return tok.start
+	}
+	return n.Pos()
+}
+
+func (p *Parser) curtokis(t TokenType) bool {
 	return p.curtok.ttype == t
 }
 
-func (p *Parser) nexttokis(t tokenType) bool {
+func (p *Parser) nexttokis(t TokenType) bool {
 	return p.nexttok.ttype == t
 }
 
-func (p *Parser) expect(t tokenType) bool {
+func (p *Parser) expect(t TokenType) bool {
 	if p.nexttokis(t) {
 		p.next()
 		return true
@@ -241,14 +764,52 @@ func (p *Parser) expect(t tokenType) bool {
 	return false
 }
 
-func (p *Parser) peekerror(t tokenType) {
-	msg := fmt.Sprintf("expected next token is %s, got %s instead", t, p.nexttok.ttype)
-	p.errors = append(p.errors, msg)
+// expectclosing is expect for the token closing a group, a missing one is
+// usually a typo so the error carries a hint.
+func (p *Parser) expectclosing(t TokenType) bool {
+	if p.expect(t) {
+		return true
+	}
+	p.hint(fmt.Sprintf("did you forget a closing `%s`?", t))
+	return false
+}
+
+func (p *Parser) peekerror(t TokenType) {
+	p.report(&ParseError{
+		Code:     ErrUnexpectedToken,
+		Expected: t,
+		Actual:   p.nexttok.ttype,
+		Message:  fmt.Sprintf("expected next token is %s, got %s instead", t, p.nexttok.ttype),
+	}, p.nexttok)
+}
+
+func (p *Parser) unexpectedeof(context string, eof token) {
+	p.report(&ParseError{
+		Code:    ErrUnexpectedEOF,
+		Actual:  EOF,
+		Message: fmt.Sprintf("unexpected EOF in %s statement", context),
+	}, eof)
+}
+
+func (p *Parser) noprefixfound(tok token) {
+	p.report(&ParseError{
+		Code:    ErrNoPrefix,
+		Actual:  tok.ttype,
+		Message: fmt.Sprintf("no prefix parse function for %s found", tok.ttype),
+	}, tok)
+}
+
+// hint attaches a suggestion to the most recently reported error.
+func (p *Parser) hint(msg string) {
+	if len(p.errors) > 0 {
+		p.errors[len(p.errors)-1].Hint = msg
+	}
 }
 
-func (p *Parser) noprefixfound(t tokenType) {
-	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+func (p *Parser) report(err *ParseError, at token) {
+	err.Pos = at.start
+	err.End = at.end
+	p.errors = append(p.errors, err)
 }
 
 func (p *Parser) peekprecedence() int {
@@ -265,10 +826,10 @@ func (p *Parser) curprecedence() int {
 	return LOWEST
 }
 
-func (p *Parser) registerprefix(tok tokenType, fn prefixparse) {
+func (p *Parser) registerprefix(tok TokenType, fn prefixparse) {
 	p.prefixparsefns[tok] = fn
 }
 
-func (p *Parser) registerinfix(tok tokenType, fn infixparse) {
+func (p *Parser) registerinfix(tok TokenType, fn infixparse) {
 	p.infixparsefns[tok] = fn
 }
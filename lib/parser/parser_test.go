@@ -2,117 +2,213 @@ package parser
 
 import (
 	"fmt"
+	"math/big"
 	"testing"
 )
 
 func TestLetStatements(t *testing.T) {
-	input := `let x = 5;
-			let y = 10;
-			let foo = 838383;`
+	tests := []struct {
+		input              string
+		expectedIdentifier string
+		expectedValue      interface{}
+	}{
+		{"let x = 5;", "x", 5},
+		{"let y = true;", "y", true},
+		{"let foo = bar;", "foo", "bar"},
+		{"let baz = 838383", "baz", 838383},
+	}
+
+	for _, tt := range tests {
+		p := NewParser(tt.input)
+		program := p.Parse()
+		checkparseerrors(t, p)
+
+		if program == nil {
+			t.Fatalf("Parse() returned nil")
+		}
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement, got %d", len(program.Statements))
+		}
+
+		stmt := program.Statements[0]
+
+		if !testLetStatement(t, stmt, tt.expectedIdentifier) {
+			return
+		}
+
+		value := stmt.(*LetStatement).Value
+		testLiteralExpression(t, value, tt.expectedValue)
+	}
+}
+
+func TestLetStatementString(t *testing.T) {
+	input := `let x = 5 * (2 + y);
+			let z = x`
 
 	p := NewParser(input)
 	program := p.Parse()
 	checkparseerrors(t, p)
 
-	if program == nil {
-		t.Fatalf("Parse() returned nil")
-	}
-
-	if len(program.statements) != 3 {
-		t.Fatalf("program.statements does not contain 3 statements, got %d", len(program.statements))
+	expected := "let x = (5 * (2 + y));let z = x;"
+	if program.String() != expected {
+		t.Errorf("program.String() wrong. expected=%q, got=%q", expected, program.String())
 	}
+}
 
+func TestLetStatementUnexpectedEOF(t *testing.T) {
 	tests := []struct {
-		expectedIdentifier string
+		input    string
+		expected string
 	}{
-		{"x"},
-		{"y"},
-		{"foo"},
+		{"let x =", "1:8: unexpected EOF in let statement"},
+		{"let x", "1:6: expected next token is =, got EOF instead"},
+		{"let", "1:4: expected next token is IDENT, got EOF instead"},
 	}
 
-	for i, tt := range tests {
-		stmt := program.statements[i]
+	for _, tt := range tests {
+		p := NewParser(tt.input)
+		p.Parse()
 
-		if !testLetStatement(t, stmt, tt.expectedIdentifier) {
-			return
+		if len(p.errors) != 1 {
+			t.Fatalf("parser has %d errors for %q, expected 1: %q", len(p.errors), tt.input, p.errors)
+		}
+
+		if p.errors[0].Error() != tt.expected {
+			t.Errorf("wrong error for %q. expected=%q, got=%q", tt.input, tt.expected, p.errors[0])
 		}
 	}
 }
 
-func testLetStatement(t *testing.T, s statement, name string) bool {
+func testLetStatement(t *testing.T, s Statement, name string) bool {
 	if s.tokenliteral() != "let" {
 		t.Errorf("s.TokenLiteral not 'let'. got=%q", s.tokenliteral())
 		return false
 	}
-	letstmt, ok := s.(*letstatement)
+	letstmt, ok := s.(*LetStatement)
 
 	if !ok {
-		t.Errorf("s not *letstatement. got=%T", s)
+		t.Errorf("s not *LetStatement. got=%T", s)
 		return false
 	}
 
-	if letstmt.name.value != name {
-		t.Errorf("letstmt.name.value not '%s'. got=%s", name, letstmt.name.value)
+	if letstmt.Name.Value != name {
+		t.Errorf("letstmt.Name.Value not '%s'. got=%s", name, letstmt.Name.Value)
 		return false
 	}
 
-	if letstmt.name.tokenliteral() != name {
-		t.Errorf("s.name not '%s'. got=%s", name, letstmt.name)
+	if letstmt.Name.tokenliteral() != name {
+		t.Errorf("s.Name not '%s'. got=%s", name, letstmt.Name)
 		return false
 	}
 	return true
 }
 
 func TestReturnStatements(t *testing.T) {
-	input := `return 5;
-			  return 10;
-			  return 90234820;`
+	tests := []struct {
+		input         string
+		expectedValue interface{}
+	}{
+		{"return 5;", 5},
+		{"return 10;", 10},
+		{"return 90234820;", 90234820},
+		{"return x", "x"},
+		{"return true;", true},
+	}
 
-	p := NewParser(input)
-	program := p.Parse()
-	checkparseerrors(t, p)
+	for _, tt := range tests {
+		p := NewParser(tt.input)
+		program := p.Parse()
+		checkparseerrors(t, p)
 
-	if program == nil {
-		t.Fatalf("Parse() returned nil")
-	}
+		if program == nil {
+			t.Fatalf("Parse() returned nil")
+		}
 
-	if len(program.statements) != 3 {
-		t.Fatalf("program.statements does not contain 3 statements, got %d", len(program.statements))
-	}
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement, got %d", len(program.Statements))
+		}
 
-	for _, stmt := range program.statements {
-		returnstmt, ok := stmt.(*returnstatement)
+		returnstmt, ok := program.Statements[0].(*ReturnStatement)
 
 		if !ok {
-			t.Errorf("stmt not *ast.returnstatement. got=%T", stmt)
-			continue
+			t.Fatalf("stmt not *ReturnStatement. got=%T", program.Statements[0])
 		}
 
 		if returnstmt.tokenliteral() != "return" {
 			t.Errorf("returnStmt.TokenLiteral not 'return', got %q", returnstmt.tokenliteral())
 		}
+
+		testLiteralExpression(t, returnstmt.Value, tt.expectedValue)
+	}
+}
+
+func TestBareReturnStatement(t *testing.T) {
+	p := NewParser("return; 5")
+	program := p.Parse()
+	checkparseerrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements, got %d", len(program.Statements))
+	}
+
+	returnstmt, ok := program.Statements[0].(*ReturnStatement)
+
+	if !ok {
+		t.Fatalf("stmt not *ReturnStatement. got=%T", program.Statements[0])
+	}
+
+	if returnstmt.Value != nil {
+		t.Errorf("returnstmt.Value is not nil. got=%T", returnstmt.Value)
+	}
+
+	if program.String() != "return;5" {
+		t.Errorf("program.String() wrong. got=%q", program.String())
+	}
+}
+
+func TestBareReturnAtEOF(t *testing.T) {
+	p := NewParser("return")
+	program := p.Parse()
+	checkparseerrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement, got %d", len(program.Statements))
+	}
+
+	returnstmt, ok := program.Statements[0].(*ReturnStatement)
+	if !ok {
+		t.Fatalf("stmt not *ReturnStatement. got=%T", program.Statements[0])
+	}
+
+	if returnstmt.Value != nil {
+		t.Errorf("returnstmt.Value is not nil. got=%T", returnstmt.Value)
+	}
+
+	if returnstmt.Pos().String() != "1:1" || returnstmt.End().String() != "1:7" {
+		t.Errorf("wrong span. expected=1:1-1:7, got=%s-%s", returnstmt.Pos(), returnstmt.End())
 	}
 }
 
 func TestString(t *testing.T) {
-	program := &program{
-		statements: []statement{
-			&letstatement{
+	program := &Program{
+		Statements: []Statement{
+			&LetStatement{
 				tok: token{ttype: LET, literal: "let"},
-				name: &identifier{
+				Name: &Identifier{
 					tok:   token{ttype: IDENT, literal: "foo"},
-					value: "foo",
+					Value: "foo",
 				},
-				value: &identifier{
+				Value: &Identifier{
 					tok:   token{ttype: IDENT, literal: "bar"},
-					value: "bar",
+					Value: "bar",
 				},
 			},
 		},
 	}
 
-	if program.tostring() != "let foo = bar;" {
-		t.Errorf("program.tostring() wrong. got=%q", program.tostring())
+	if program.String() != "let foo = bar;" {
+		t.Errorf("program.String() wrong. got=%q", program.String())
 	}
 }
 
@@ -122,17 +218,17 @@ func TestIdenfierExpressions(t *testing.T) {
 	prog := p.Parse()
 	checkparseerrors(t, p)
 
-	if len(prog.statements) != 1 {
-		t.Fatalf("program doesn't have enough statements, got %d", len(prog.statements))
+	if len(prog.Statements) != 1 {
+		t.Fatalf("program doesn't have enough statements, got %d", len(prog.Statements))
 	}
 
-	stmt, ok := prog.statements[0].(*expressionstatement)
+	stmt, ok := prog.Statements[0].(*ExpressionStatement)
 
 	if !ok {
-		t.Fatalf("prog.statement[0] is not an expression statement, got %T", prog.statements[0])
+		t.Fatalf("prog.statement[0] is not an expression statement, got %T", prog.Statements[0])
 	}
 
-	testIdent(t, stmt.expr, input)
+	testIdent(t, stmt.Expr, input)
 }
 
 func TestIntegerLiteral(t *testing.T) {
@@ -141,24 +237,24 @@ func TestIntegerLiteral(t *testing.T) {
 	prog := p.Parse()
 	checkparseerrors(t, p)
 
-	if len(prog.statements) != 1 {
-		t.Fatalf("program doesn't have enough statements, got %d", len(prog.statements))
+	if len(prog.Statements) != 1 {
+		t.Fatalf("program doesn't have enough statements, got %d", len(prog.Statements))
 	}
 
-	stmt, ok := prog.statements[0].(*expressionstatement)
+	stmt, ok := prog.Statements[0].(*ExpressionStatement)
 
 	if !ok {
-		t.Fatalf("prog.statement[0] is not an expression statement, got %T", prog.statements[0])
+		t.Fatalf("prog.statement[0] is not an expression statement, got %T", prog.Statements[0])
 	}
 
-	literal, ok := stmt.expr.(*intliteral)
+	literal, ok := stmt.Expr.(*IntLiteral)
 
 	if !ok {
-		t.Fatalf("expression.expr is not an integer literal, got %T", literal.value)
+		t.Fatalf("expression.Expr is not an integer literal, got %T", literal.Value)
 	}
 
-	if literal.value != 5 {
-		t.Errorf("literal.value not %d, got %d", 5, literal.value)
+	if literal.Value != 5 {
+		t.Errorf("literal.Value not %d, got %d", 5, literal.Value)
 	}
 
 	if literal.tokenliteral() != "5" {
@@ -166,20 +262,126 @@ func TestIntegerLiteral(t *testing.T) {
 	}
 }
 
-func testIdent(t *testing.T, expr expression, value string) {
-	ident, ok := expr.(*identifier)
+func TestNumberLiteralValues(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"1_000", int64(1000)},
+		{"0xff", int64(255)},
+		{"0o17", int64(15)},
+		{"0", int64(0)},
+		{"0.5", 0.5},
+		{"0b1010", int64(10)},
+		{"3.14", 3.14},
+		{"1e-9", 1e-9},
+		{"1_000.5", 1000.5},
+	}
+
+	for _, tt := range tests {
+		p := NewParser(tt.input)
+		prog := p.Parse()
+		checkparseerrors(t, p)
+
+		expr := prog.Statements[0].(*ExpressionStatement).Expr
+
+		switch expected := tt.expected.(type) {
+		case int64:
+			lit, ok := expr.(*IntLiteral)
+			if !ok || lit.Value != expected {
+				t.Errorf("%q is not IntLiteral %d. got=%T(%s)", tt.input, expected, expr, expr)
+			}
+		case float64:
+			lit, ok := expr.(*FloatLiteral)
+			if !ok || lit.Value != expected {
+				t.Errorf("%q is not FloatLiteral %g. got=%T(%s)", tt.input, expected, expr, expr)
+			}
+		}
+	}
+}
+
+func TestBigIntegerLiteral(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"9223372036854775808", "9223372036854775808"},
+		{"123_456_789_012_345_678_901_234_567_890", "123456789012345678901234567890"},
+		{"0xffff_ffff_ffff_ffff_ffff", "1208925819614629174706175"},
+	}
+
+	for _, tt := range tests {
+		p := NewParser(tt.input)
+		prog := p.Parse()
+		checkparseerrors(t, p)
+
+		lit, ok := prog.Statements[0].(*ExpressionStatement).Expr.(*IntLiteral)
+		if !ok {
+			t.Fatalf("%q is not IntLiteral. got=%T", tt.input, prog.Statements[0].(*ExpressionStatement).Expr)
+		}
+
+		if lit.Big == nil || lit.Big.String() != tt.expected {
+			t.Errorf("%q has wrong big value. expected=%s, got=%v", tt.input, tt.expected, lit.Big)
+		}
+
+		if lit.String() != tt.input {
+			t.Errorf("lit.String() wrong. expected=%q, got=%q", tt.input, lit.String())
+		}
+	}
+
+	p := NewParser("9223372036854775807")
+	lit := p.Parse().Statements[0].(*ExpressionStatement).Expr.(*IntLiteral)
+	if lit.Big != nil || lit.Value != 9223372036854775807 {
+		t.Errorf("max int64 literal was not kept small. got=%d %v", lit.Value, lit.Big)
+	}
+}
+
+func TestInvalidNumberLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		code     ErrorCode
+		expected string
+	}{
+		{"0b102", ErrInvalidInteger, `1:1: could not parse "0b102" as integer`},
+		{"1__0", ErrInvalidInteger, `1:1: could not parse "1__0" as integer`},
+		{"08", ErrInvalidInteger, `1:1: decimal integer "08" can't start with 0, write octal with 0o`},
+		{"017", ErrInvalidInteger, `1:1: decimal integer "017" can't start with 0, write octal with 0o`},
+		{"0_1", ErrInvalidInteger, `1:1: decimal integer "0_1" can't start with 0, write octal with 0o`},
+		{"0x", ErrInvalidInteger, `1:1: could not parse "0x" as integer`},
+		{"1e", ErrMalformedNumber, `1:1: malformed number "1e"`},
+		{"x + 2else", ErrMalformedNumber, `1:5: malformed number "2else"`},
+		{"1_.5", ErrInvalidFloat, `1:1: could not parse "1_.5" as float`},
+		{"1e+_5", ErrInvalidFloat, `1:1: could not parse "1e+_5" as float`},
+	}
+
+	for _, tt := range tests {
+		p := NewParser(tt.input)
+		p.Parse()
+
+		if len(p.Errors()) != 1 {
+			t.Fatalf("expected 1 error for %q. got=%q", tt.input, p.Errors())
+		}
+
+		if p.Errors()[0].Code != tt.code || p.Errors()[0].Error() != tt.expected {
+			t.Errorf("wrong error for %q. expected=%s %q, got=%s %q", tt.input, tt.code, tt.expected, p.Errors()[0].Code, p.Errors()[0].Error())
+		}
+	}
+}
+
+func testIdent(t *testing.T, expr Expression, value string) {
+	ident, ok := expr.(*Identifier)
 	if !ok {
-		t.Errorf("expr not *identifier. got=%T", expr)
+		t.Errorf("expr not *Identifier. got=%T", expr)
 	}
-	if ident.value != value {
-		t.Errorf("ident.value not %s. got=%s", value, ident.value)
+	if ident.Value != value {
+		t.Errorf("ident.Value not %s. got=%s", value, ident.Value)
 	}
 	if ident.tokenliteral() != value {
 		t.Errorf("ident.tokenliteral() not %s. got=%s", value, ident.tokenliteral())
 	}
 }
 
-func testLiteralExpression(t *testing.T, expr expression, expected interface{}) {
+func testLiteralExpression(t *testing.T, expr Expression, expected interface{}) {
 	switch v := expected.(type) {
 	case int:
 		testIntegerLiteral(t, expr, int64(v))
@@ -209,25 +411,25 @@ func TestParsingPrefixExpressions(t *testing.T) {
 		prog := p.Parse()
 		checkparseerrors(t, p)
 
-		if len(prog.statements) != 1 {
-			t.Fatalf("prog.statements doesn't contain %d statements, got %d\n", 1, len(prog.statements))
+		if len(prog.Statements) != 1 {
+			t.Fatalf("prog.Statements doesn't contain %d statements, got %d\n", 1, len(prog.Statements))
 		}
 
-		stmt, ok := prog.statements[0].(*expressionstatement)
+		stmt, ok := prog.Statements[0].(*ExpressionStatement)
 		if !ok {
-			t.Fatalf("prog.statements[0] is not a expression statement, got %T\n", stmt)
+			t.Fatalf("prog.Statements[0] is not a expression statement, got %T\n", stmt)
 		}
 
-		expr, ok := stmt.expr.(*prefixexpr)
+		expr, ok := stmt.Expr.(*PrefixExpr)
 		if !ok {
-			t.Fatalf("stmt is not prefixexpr. got=%T", stmt.expr)
+			t.Fatalf("stmt is not prefixexpr. got=%T", stmt.Expr)
 		}
 
-		if expr.operator != tt.operator {
-			t.Fatalf("expr.operator is not '%s'. got=%s", tt.operator, expr.operator)
+		if expr.Operator != tt.operator {
+			t.Fatalf("expr.Operator is not '%s'. got=%s", tt.operator, expr.Operator)
 		}
 
-		testLiteralExpression(t, expr.right, tt.intval)
+		testLiteralExpression(t, expr.Right, tt.intval)
 	}
 }
 
@@ -253,37 +455,37 @@ func TestParsingInfixExpressions(t *testing.T) {
 		prog := p.Parse()
 		checkparseerrors(t, p)
 
-		if len(prog.statements) != 1 {
-			t.Fatalf("prog.statements does not contain %d statements. got=%d\n", 1, len(prog.statements))
+		if len(prog.Statements) != 1 {
+			t.Fatalf("prog.Statements does not contain %d statements. got=%d\n", 1, len(prog.Statements))
 		}
 
-		stmt, ok := prog.statements[0].(*expressionstatement)
+		stmt, ok := prog.Statements[0].(*ExpressionStatement)
 		if !ok {
-			t.Fatalf("prog.statements[0] is not expressionstatement. got=%T",
-				prog.statements[0])
+			t.Fatalf("prog.Statements[0] is not expressionstatement. got=%T",
+				prog.Statements[0])
 		}
-		expr, ok := stmt.expr.(*infixexpr)
+		expr, ok := stmt.Expr.(*InfixExpr)
 		if !ok {
-			t.Fatalf("expr is not infixexpr. got=%T", stmt.expr)
+			t.Fatalf("expr is not infixexpr. got=%T", stmt.Expr)
 		}
-		testLiteralExpression(t, expr.left, tt.left)
-		if expr.operator != tt.operator {
-			t.Fatalf("expr.operator is not '%s'. got=%s", tt.operator, expr.operator)
+		testLiteralExpression(t, expr.Left, tt.left)
+		if expr.Operator != tt.operator {
+			t.Fatalf("expr.Operator is not '%s'. got=%s", tt.operator, expr.Operator)
 		}
 
-		testLiteralExpression(t, expr.right, tt.right)
+		testLiteralExpression(t, expr.Right, tt.right)
 	}
 }
 
-func testIntegerLiteral(t *testing.T, i expression, value int64) bool {
-	integer, ok := i.(*intliteral)
+func testIntegerLiteral(t *testing.T, i Expression, value int64) bool {
+	integer, ok := i.(*IntLiteral)
 	if !ok {
 		t.Errorf("i not intliteral. got=%T", i)
 		return false
 	}
 
-	if integer.value != value {
-		t.Errorf("integ.Value not %d. got=%d", value, integer.value)
+	if integer.Value != value {
+		t.Errorf("integ.Value not %d. got=%d", value, integer.Value)
 		return false
 	}
 	if integer.tokenliteral() != fmt.Sprintf("%d", value) {
@@ -369,13 +571,53 @@ func TestOperatorPrecedence(t *testing.T) {
 			"!(true == true)",
 			"(!(true == true))",
 		},
+		{
+			"a + add(b * c) + d",
+			"((a + add((b * c))) + d)",
+		},
+		{
+			"add(a, b, 1, 2 * 3, 4 + 5, add(6, 7 * 8))",
+			"add(a, b, 1, (2 * 3), (4 + 5), add(6, (7 * 8)))",
+		},
+		{
+			"add(a + b + c * d / f + g)",
+			"add((((a + b) + ((c * d) / f)) + g))",
+		},
+		{
+			"-f(x) * 2",
+			"((-f(x)) * 2)",
+		},
+		{
+			"a * [1, 2, 3, 4][b * c] * d",
+			"((a * ([1, 2, 3, 4][(b * c)])) * d)",
+		},
+		{
+			"add(a * b[2], b[1], 2 * [1, 2][1])",
+			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
+		},
+		{
+			"-a[0]",
+			"(-(a[0]))",
+		},
+		{
+			"f(x)[0](y)",
+			"(f(x)[0])(y)",
+		},
+		{
+			"a[1:n - 1][0]",
+			"((a[1:(n - 1)])[0])",
+		},
+		{
+			"a[-2:]",
+			"(a[(-2):])",
+		},
 	}
 
 	for _, tt := range tests {
 		p := NewParser(tt.input)
 		prog := p.Parse()
 		checkparseerrors(t, p)
-		actual := prog.tostring()
+		actual := prog.String()
 		if actual != tt.expected {
 			t.Errorf("expected=%q, got=%q", tt.expected, actual)
 		}
@@ -396,25 +638,854 @@ func TestBoolExpression(t *testing.T) {
 		prog := p.Parse()
 		checkparseerrors(t, p)
 
-		if len(prog.statements) != 1 {
-			t.Fatalf("prog.statements does not contain %d statements. got=%d\n", 1, len(prog.statements))
+		if len(prog.Statements) != 1 {
+			t.Fatalf("prog.Statements does not contain %d statements. got=%d\n", 1, len(prog.Statements))
 		}
 
-		stmt, ok := prog.statements[0].(*expressionstatement)
+		stmt, ok := prog.Statements[0].(*ExpressionStatement)
 		if !ok {
-			t.Fatalf("prog.statements[0] is not expressionstatement. got=%T", prog.statements[0])
+			t.Fatalf("prog.Statements[0] is not expressionstatement. got=%T", prog.Statements[0])
+		}
+		testLiteralExpression(t, stmt.Expr, tt.expected)
+	}
+}
+
+func TestIfExpression(t *testing.T) {
+	input := `if (x < y) { x }`
+
+	p := NewParser(input)
+	prog := p.Parse()
+	checkparseerrors(t, p)
+
+	if len(prog.Statements) != 1 {
+		t.Fatalf("prog.Statements does not contain %d statements. got=%d\n", 1, len(prog.Statements))
+	}
+
+	stmt, ok := prog.Statements[0].(*ExpressionStatement)
+	if !ok {
+		t.Fatalf("prog.Statements[0] is not expressionstatement. got=%T", prog.Statements[0])
+	}
+
+	expr, ok := stmt.Expr.(*IfExpr)
+	if !ok {
+		t.Fatalf("stmt.Expr is not ifexpr. got=%T", stmt.Expr)
+	}
+
+	testInfixExpression(t, expr.Condition, "x", "<", "y")
+
+	if len(expr.Consequence.Statements) != 1 {
+		t.Fatalf("consequence is not 1 statement. got=%d\n", len(expr.Consequence.Statements))
+	}
+
+	consequence, ok := expr.Consequence.Statements[0].(*ExpressionStatement)
+	if !ok {
+		t.Fatalf("consequence.Statements[0] is not expressionstatement. got=%T", expr.Consequence.Statements[0])
+	}
+
+	testIdent(t, consequence.Expr, "x")
+
+	if expr.Alternative != nil {
+		t.Errorf("expr.Alternative was not nil. got=%+v", expr.Alternative)
+	}
+}
+
+func TestIfElseExpression(t *testing.T) {
+	input := `if (x < y) { x } else { y }`
+
+	p := NewParser(input)
+	prog := p.Parse()
+	checkparseerrors(t, p)
+
+	if len(prog.Statements) != 1 {
+		t.Fatalf("prog.Statements does not contain %d statements. got=%d\n", 1, len(prog.Statements))
+	}
+
+	stmt, ok := prog.Statements[0].(*ExpressionStatement)
+	if !ok {
+		t.Fatalf("prog.Statements[0] is not expressionstatement. got=%T", prog.Statements[0])
+	}
+
+	expr, ok := stmt.Expr.(*IfExpr)
+	if !ok {
+		t.Fatalf("stmt.Expr is not ifexpr. got=%T", stmt.Expr)
+	}
+
+	testInfixExpression(t, expr.Condition, "x", "<", "y")
+
+	consequence, ok := expr.Consequence.Statements[0].(*ExpressionStatement)
+	if !ok {
+		t.Fatalf("consequence.Statements[0] is not expressionstatement. got=%T", expr.Consequence.Statements[0])
+	}
+
+	testIdent(t, consequence.Expr, "x")
+
+	if expr.Alternative == nil || len(expr.Alternative.Statements) != 1 {
+		t.Fatalf("expr.Alternative does not contain 1 statement. got=%+v", expr.Alternative)
+	}
+
+	alternative, ok := expr.Alternative.Statements[0].(*ExpressionStatement)
+	if !ok {
+		t.Fatalf("alternative.Statements[0] is not expressionstatement. got=%T", expr.Alternative.Statements[0])
+	}
+
+	testIdent(t, alternative.Expr, "y")
+}
+
+func TestElseIfExpression(t *testing.T) {
+	input := `if (a) { 1 } else if (b) { 2 } else { 3 }`
+
+	p := NewParser(input)
+	prog := p.Parse()
+	checkparseerrors(t, p)
+
+	if len(prog.Statements) != 1 {
+		t.Fatalf("prog.Statements does not contain %d statements. got=%d\n", 1, len(prog.Statements))
+	}
+
+	expr, ok := prog.Statements[0].(*ExpressionStatement).Expr.(*IfExpr)
+	if !ok {
+		t.Fatalf("stmt.Expr is not ifexpr. got=%T", prog.Statements[0])
+	}
+
+	if expr.Alternative == nil || len(expr.Alternative.Statements) != 1 {
+		t.Fatalf("expr.Alternative does not contain 1 statement. got=%+v", expr.Alternative)
+	}
+
+	chained, ok := expr.Alternative.Statements[0].(*ExpressionStatement).Expr.(*IfExpr)
+	if !ok {
+		t.Fatalf("alternative is not a chained ifexpr. got=%T", expr.Alternative.Statements[0])
+	}
+
+	testIdent(t, chained.Condition, "b")
+
+	if chained.Alternative == nil {
+		t.Fatalf("chained.Alternative is nil")
+	}
+
+	last := chained.Alternative.Statements[0].(*ExpressionStatement)
+	testLiteralExpression(t, last.Expr, 3)
+}
+
+func TestIfExpressionString(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"if (x < y) { x }",
+			"if ((x < y)) { x }",
+		},
+		{
+			"if (x) { let y = 1; y } else { return 2; }",
+			"if (x) { let y = 1; y } else { return 2; }",
+		},
+		{
+			"if (a) { 1 } else if (b) { 2 } else { 3 }",
+			"if (a) { 1 } else { if (b) { 2 } else { 3 } }",
+		},
+		{
+			"if (a) {}",
+			"if (a) {}",
+		},
+	}
+
+	for _, tt := range tests {
+		p := NewParser(tt.input)
+		prog := p.Parse()
+		checkparseerrors(t, p)
+
+		actual := prog.String()
+		if actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+
+		p = NewParser(actual)
+		reparsed := p.Parse()
+		checkparseerrors(t, p)
+
+		if reparsed.String() != actual {
+			t.Errorf("round trip changed output. expected=%q, got=%q", actual, reparsed.String())
 		}
-		testLiteralExpression(t, stmt.expr, tt.expected)
 	}
 }
 
-func testBoolLiteral(t *testing.T, expr expression, value bool) {
-	bexpr, ok := expr.(*boolexpr)
+func TestUnterminatedBlock(t *testing.T) {
+	p := NewParser("if (x) { let y = 1;")
+	p.Parse()
+
+	if len(p.errors) != 1 {
+		t.Fatalf("parser has %d errors, expected 1: %q", len(p.errors), p.errors)
+	}
+
+	if p.errors[0].Error() != "1:20: unexpected EOF in block statement" {
+		t.Errorf("wrong error. got=%q", p.errors[0])
+	}
+}
+
+func TestFnLiteral(t *testing.T) {
+	input := `fn(x, y) { x + y; }`
+
+	p := NewParser(input)
+	prog := p.Parse()
+	checkparseerrors(t, p)
+
+	if len(prog.Statements) != 1 {
+		t.Fatalf("prog.Statements does not contain %d statements. got=%d\n", 1, len(prog.Statements))
+	}
+
+	stmt, ok := prog.Statements[0].(*ExpressionStatement)
+	if !ok {
+		t.Fatalf("prog.Statements[0] is not expressionstatement. got=%T", prog.Statements[0])
+	}
+
+	fn, ok := stmt.Expr.(*FnLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expr is not fnliteral. got=%T", stmt.Expr)
+	}
+
+	if len(fn.Parameters) != 2 {
+		t.Fatalf("fn.Parameters wrong. want 2, got=%d\n", len(fn.Parameters))
+	}
+
+	testLiteralExpression(t, fn.Parameters[0], "x")
+	testLiteralExpression(t, fn.Parameters[1], "y")
+
+	if len(fn.Body.Statements) != 1 {
+		t.Fatalf("fn.Body.Statements has not 1 statement. got=%d\n", len(fn.Body.Statements))
+	}
+
+	body, ok := fn.Body.Statements[0].(*ExpressionStatement)
+	if !ok {
+		t.Fatalf("fn body stmt is not expressionstatement. got=%T", fn.Body.Statements[0])
+	}
+
+	testInfixExpression(t, body.Expr, "x", "+", "y")
+}
+
+func TestFnParameters(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedParams []string
+	}{
+		{input: "fn() {};", expectedParams: []string{}},
+		{input: "fn(x) {};", expectedParams: []string{"x"}},
+		{input: "fn(x, y, z) {};", expectedParams: []string{"x", "y", "z"}},
+	}
+
+	for _, tt := range tests {
+		p := NewParser(tt.input)
+		prog := p.Parse()
+		checkparseerrors(t, p)
+
+		stmt := prog.Statements[0].(*ExpressionStatement)
+		fn := stmt.Expr.(*FnLiteral)
+
+		if len(fn.Parameters) != len(tt.expectedParams) {
+			t.Errorf("length parameters wrong. want %d, got=%d\n", len(tt.expectedParams), len(fn.Parameters))
+		}
+
+		for i, ident := range tt.expectedParams {
+			testLiteralExpression(t, fn.Parameters[i], ident)
+		}
+	}
+}
+
+func TestCallExpression(t *testing.T) {
+	input := "add(1, 2 * 3, 4 + 5);"
+
+	p := NewParser(input)
+	prog := p.Parse()
+	checkparseerrors(t, p)
+
+	if len(prog.Statements) != 1 {
+		t.Fatalf("prog.Statements does not contain %d statements. got=%d\n", 1, len(prog.Statements))
+	}
+
+	stmt, ok := prog.Statements[0].(*ExpressionStatement)
+	if !ok {
+		t.Fatalf("prog.Statements[0] is not expressionstatement. got=%T", prog.Statements[0])
+	}
+
+	expr, ok := stmt.Expr.(*CallExpr)
+	if !ok {
+		t.Fatalf("stmt.Expr is not callexpr. got=%T", stmt.Expr)
+	}
+
+	testIdent(t, expr.Function, "add")
+
+	if len(expr.Arguments) != 3 {
+		t.Fatalf("wrong length of arguments. got=%d", len(expr.Arguments))
+	}
+
+	testLiteralExpression(t, expr.Arguments[0], 1)
+	testInfixExpression(t, expr.Arguments[1], 2, "*", 3)
+	testInfixExpression(t, expr.Arguments[2], 4, "+", 5)
+}
+
+func TestHigherOrderFunctions(t *testing.T) {
+	input := `let add = fn(a, b) { a + b }; add(1, 2)`
+
+	p := NewParser(input)
+	prog := p.Parse()
+	checkparseerrors(t, p)
+
+	expected := "let add = fn(a, b) { (a + b) };add(1, 2)"
+	if prog.String() != expected {
+		t.Errorf("prog.String() wrong. expected=%q, got=%q", expected, prog.String())
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(x) { x }(5)", "fn(x) { x }(5)"},
+		{"fn(f) { f(1) * 2 }(g)", "fn(f) { (f(1) * 2) }(g)"},
+		{"let compose = fn(f, g) { fn(x) { f(g(x)) } };", "let compose = fn(f, g) { fn(x) { f(g(x)) } };"},
+	}
+
+	for _, tt := range tests {
+		p := NewParser(tt.input)
+		prog := p.Parse()
+		checkparseerrors(t, p)
+
+		if prog.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, prog.String())
+		}
+	}
+}
+
+func TestArrayLiteral(t *testing.T) {
+	input := "[1, 2 * 2, 3 + 3]"
+
+	p := NewParser(input)
+	prog := p.Parse()
+	checkparseerrors(t, p)
+
+	stmt := prog.Statements[0].(*ExpressionStatement)
+	array, ok := stmt.Expr.(*ArrayLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expr is not arrayliteral. got=%T", stmt.Expr)
+	}
+
+	if len(array.Elements) != 3 {
+		t.Fatalf("len(array.Elements) not 3. got=%d", len(array.Elements))
+	}
+
+	testIntegerLiteral(t, array.Elements[0], 1)
+	testInfixExpression(t, array.Elements[1], 2, "*", 2)
+	testInfixExpression(t, array.Elements[2], 3, "+", 3)
+
+	if array.Pos().String() != "1:1" || array.End().String() != "1:18" {
+		t.Errorf("array span wrong. got=%s-%s", array.Pos(), array.End())
+	}
+
+	p = NewParser("[]")
+	prog = p.Parse()
+	checkparseerrors(t, p)
+
+	array = prog.Statements[0].(*ExpressionStatement).Expr.(*ArrayLiteral)
+	if len(array.Elements) != 0 {
+		t.Errorf("len(array.Elements) not 0. got=%d", len(array.Elements))
+	}
+}
+
+func TestIndexExpression(t *testing.T) {
+	input := "myArray[1 + 1]"
+
+	p := NewParser(input)
+	prog := p.Parse()
+	checkparseerrors(t, p)
+
+	stmt := prog.Statements[0].(*ExpressionStatement)
+	expr, ok := stmt.Expr.(*IndexExpr)
+	if !ok {
+		t.Fatalf("stmt.Expr is not indexexpr. got=%T", stmt.Expr)
+	}
+
+	testIdent(t, expr.Left, "myArray")
+	testInfixExpression(t, expr.Index, 1, "+", 1)
+
+	if expr.Pos().String() != "1:1" || expr.End().String() != "1:15" {
+		t.Errorf("index span wrong. got=%s-%s", expr.Pos(), expr.End())
+	}
+}
+
+func TestSliceExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		low      interface{}
+		high     interface{}
+		expected string
+	}{
+		{"a[1:3]", 1, 3, "(a[1:3])"},
+		{"a[:3]", nil, 3, "(a[:3])"},
+		{"a[1:]", 1, nil, "(a[1:])"},
+		{"a[:]", nil, nil, "(a[:])"},
+	}
+
+	for _, tt := range tests {
+		p := NewParser(tt.input)
+		prog := p.Parse()
+		checkparseerrors(t, p)
+
+		slice, ok := prog.Statements[0].(*ExpressionStatement).Expr.(*SliceExpr)
+		if !ok {
+			t.Fatalf("%q is not sliceexpr. got=%T", tt.input, prog.Statements[0].(*ExpressionStatement).Expr)
+		}
+
+		testIdent(t, slice.Left, "a")
+
+		if tt.low == nil && slice.Low != nil {
+			t.Errorf("%q - slice.Low not nil. got=%s", tt.input, slice.Low)
+		} else if tt.low != nil {
+			testLiteralExpression(t, slice.Low, tt.low)
+		}
+
+		if tt.high == nil && slice.High != nil {
+			t.Errorf("%q - slice.High not nil. got=%s", tt.input, slice.High)
+		} else if tt.high != nil {
+			testLiteralExpression(t, slice.High, tt.high)
+		}
+
+		if slice.String() != tt.expected {
+			t.Errorf("slice.String() wrong. expected=%q, got=%q", tt.expected, slice.String())
+		}
+	}
+}
+
+func TestHashLiteral(t *testing.T) {
+	input := `{"one": 1, "two": 2, 3: true, false: 1 + 1}`
+
+	p := NewParser(input)
+	prog := p.Parse()
+	checkparseerrors(t, p)
+
+	stmt := prog.Statements[0].(*ExpressionStatement)
+	hash, ok := stmt.Expr.(*HashLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expr is not hashliteral. got=%T", stmt.Expr)
+	}
+
+	if len(hash.Pairs) != 4 {
+		t.Fatalf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+	}
+
+	keys := []string{`"one"`, `"two"`, "3", "false"}
+	for i, pair := range hash.Pairs {
+		if pair.Key.String() != keys[i] {
+			t.Errorf("hash.Pairs[%d] has wrong key. expected=%s, got=%s", i, keys[i], pair.Key)
+		}
+	}
+
+	testIntegerLiteral(t, hash.Pairs[0].Value, 1)
+	testIntegerLiteral(t, hash.Pairs[1].Value, 2)
+	testBoolLiteral(t, hash.Pairs[2].Value, true)
+	testInfixExpression(t, hash.Pairs[3].Value, 1, "+", 1)
+
+	if hash.String() != `{"one": 1, "two": 2, 3: true, false: (1 + 1)}` {
+		t.Errorf("hash.String() wrong. got=%q", hash.String())
+	}
+
+	if hash.Pos().String() != "1:1" || hash.End().String() != "1:44" {
+		t.Errorf("hash span wrong. got=%s-%s", hash.Pos(), hash.End())
+	}
+}
+
+func TestHashLiteralPositions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"{}", "{}"},
+		{`{"a": 1}["a"]`, `({"a": 1}["a"])`},
+		{`let h = {"f": fn(x) { x }}; h["f"](1)`, `let h = {"f": fn(x) { x }};(h["f"])(1)`},
+		{`fn() { {"a": 1} }`, `fn() { {"a": 1} }`},
+		{`if (x) { {} } else { {1: 2} }`, "if (x) { {} } else { {1: 2} }"},
+	}
+
+	for _, tt := range tests {
+		p := NewParser(tt.input)
+		prog := p.Parse()
+		checkparseerrors(t, p)
+
+		if prog.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, prog.String())
+		}
+	}
+}
+
+func TestUnclosedBrackets(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[1, 2", "1:6: expected next token is ], got EOF instead"},
+		{"a[1", "1:4: expected next token is ], got EOF instead"},
+		{"a[1:2", "1:6: expected next token is ], got EOF instead"},
+		{"a[]", "1:3: no prefix parse function for ] found"},
+		{`{"a": 1`, "1:8: expected next token is }, got EOF instead"},
+		{`{"a" 1}`, "1:6: expected next token is :, got INT instead"},
+		{`{"a": 1,}`, "1:9: no prefix parse function for } found"},
+	}
+
+	for _, tt := range tests {
+		p := NewParser(tt.input)
+		p.Parse()
+
+		if len(p.Errors()) == 0 {
+			t.Fatalf("%q - expected errors", tt.input)
+		}
+
+		if p.Errors()[0].Error() != tt.expected {
+			t.Errorf("%q - wrong error. expected=%q, got=%q", tt.input, tt.expected, p.Errors()[0].Error())
+		}
+	}
+
+	p := NewParser("[1, 2")
+	p.Parse()
+	if p.Errors()[0].Hint != "did you forget a closing `]`?" {
+		t.Errorf("wrong hint. got=%q", p.Errors()[0].Hint)
+	}
+}
+
+func TestNodeSpans(t *testing.T) {
+	input := `let add = fn(a, b) {
+	a + b
+};
+add(1, -2 * 3);`
+
+	p := NewParser(input)
+	prog := p.Parse()
+	checkparseerrors(t, p)
+
+	let := prog.Statements[0].(*LetStatement)
+	fn := let.Value.(*FnLiteral)
+	sum := fn.Body.Statements[0].(*ExpressionStatement).Expr
+	call := prog.Statements[1].(*ExpressionStatement).Expr.(*CallExpr)
+	product := call.Arguments[1]
+
+	tests := []struct {
+		node  Node
+		start string
+		end   string
+	}{
+		{prog, "1:1", "4:16"},
+		{let, "1:1", "3:3"},
+		{let.Name, "1:5", "1:8"},
+		{fn, "1:11", "3:2"},
+		{fn.Parameters[1], "1:17", "1:18"},
+		{fn.Body, "1:20", "3:2"},
+		{sum, "2:2", "2:7"},
+		{prog.Statements[1], "4:1", "4:16"},
+		{call, "4:1", "4:15"},
+		{call.Arguments[0], "4:5", "4:6"},
+		{product, "4:8", "4:14"},
+		{product.(*InfixExpr).Left, "4:8", "4:10"},
+	}
+
+	for i, tt := range tests {
+		if tt.node.Pos().String() != tt.start {
+			t.Errorf("tests[%d] %q - start wrong. expected=%s, got=%s", i, tt.node.String(), tt.start, tt.node.Pos())
+		}
+
+		if tt.node.End().String() != tt.end {
+			t.Errorf("tests[%d] %q - end wrong. expected=%s, got=%s", i, tt.node.String(), tt.end, tt.node.End())
+		}
+	}
+}
+
+func TestErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input      string
+		errors     []string
+		statements []string
+	}{
+		{
+			"let = 5; let y = 10;",
+			[]string{"1:5: expected next token is IDENT, got = instead"},
+			[]string{"<bad statement>", "let y = 10;"},
+		},
+		{
+			"let x = 5 +; x * 2",
+			[]string{"1:12: no prefix parse function for ; found"},
+			[]string{"<bad statement>", "(x * 2)"},
+		},
+		{
+			"add(1, 2 let x = 1",
+			[]string{"1:10: expected next token is ), got LET instead"},
+			[]string{"<bad statement>", "let x = 1;"},
+		},
+		{
+			"if (x { 1 } return 2;",
+			[]string{"1:7: expected next token is ), got { instead"},
+			[]string{"<bad statement>", "return 2;"},
+		},
+		{
+			"let f = fn(x) { x + }; f(1)",
+			[]string{"1:21: no prefix parse function for } found"},
+			[]string{"let f = fn(x) { <bad statement> };", "f(1)"},
+		},
+		{
+			"let f = fn(x) { let = 1; x }; f",
+			[]string{"1:21: expected next token is IDENT, got = instead"},
+			[]string{"let f = fn(x) { <bad statement> x };", "f"},
+		},
+		{
+			") * 2; let a = 1; let = 3; a",
+			[]string{
+				"1:1: no prefix parse function for ) found",
+				"1:23: expected next token is IDENT, got = instead",
+			},
+			[]string{"<bad statement>", "let a = 1;", "<bad statement>", "a"},
+		},
+		{
+			"1 } 2",
+			[]string{"1:3: no prefix parse function for } found"},
+			[]string{"1", "<bad statement>", "2"},
+		},
+		{
+			"fn(x) { x }}; let y = 1",
+			[]string{"1:12: no prefix parse function for } found"},
+			[]string{"fn(x) { x }", "<bad statement>", "let y = 1;"},
+		},
+		{
+			"let f = fn() { let h = {1 2}; h }; f",
+			[]string{"1:27: expected next token is :, got INT instead"},
+			[]string{"let f = fn() { <bad statement> h };", "f"},
+		},
+		{
+			`let h = {"a": 1 "b": 2}; h`,
+			[]string{"1:17: expected next token is }, got STRING instead"},
+			[]string{"<bad statement>", "h"},
+		},
+	}
+
+	for _, tt := range tests {
+		p := NewParser(tt.input)
+		prog := p.Parse()
+
+		if len(p.Errors()) != len(tt.errors) {
+			t.Errorf("wrong number of errors for %q. expected=%d, got=%d: %q", tt.input, len(tt.errors), len(p.Errors()), p.Errors())
+			continue
+		}
+
+		for i, msg := range tt.errors {
+			if p.Errors()[i].Error() != msg {
+				t.Errorf("errors[%d] wrong for %q. expected=%q, got=%q", i, tt.input, msg, p.Errors()[i].Error())
+			}
+		}
+
+		if len(prog.Statements) != len(tt.statements) {
+			t.Errorf("wrong number of statements for %q. expected=%d, got=%d: %q", tt.input, len(tt.statements), len(prog.Statements), prog.String())
+			continue
+		}
+
+		for i, expected := range tt.statements {
+			if prog.Statements[i].String() != expected {
+				t.Errorf("statements[%d] wrong for %q. expected=%q, got=%q", i, tt.input, expected, prog.Statements[i].String())
+			}
+		}
+	}
+}
+
+func TestBadStatementSpan(t *testing.T) {
+	p := NewParser("let = 5;\nlet y = 1;")
+	prog := p.Parse()
+
+	bad, ok := prog.Statements[0].(*BadStatement)
+	if !ok {
+		t.Fatalf("prog.Statements[0] is not BadStatement. got=%T", prog.Statements[0])
+	}
+
+	if bad.Pos().String() != "1:1" || bad.End().String() != "1:9" {
+		t.Errorf("bad statement span wrong. got=%s-%s", bad.Pos(), bad.End())
+	}
+}
+
+func TestStringLiteral(t *testing.T) {
+	input := `"hello\tworld";`
+
+	p := NewParser(input)
+	prog := p.Parse()
+	checkparseerrors(t, p)
+
+	stmt := prog.Statements[0].(*ExpressionStatement)
+	literal, ok := stmt.Expr.(*StringLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expr is not StringLiteral. got=%T", stmt.Expr)
+	}
+
+	if literal.Value != "hello\tworld" {
+		t.Errorf("literal.Value not %q. got=%q", "hello\tworld", literal.Value)
+	}
+
+	if literal.Pos().String() != "1:1" || literal.End().String() != "1:15" {
+		t.Errorf("literal span wrong. got=%s-%s", literal.Pos(), literal.End())
+	}
+}
+
+func TestStringLiteralString(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"a" + "b"`, `("a" + "b")`},
+		{`let s = "line\n\"quoted\"\\"`, `let s = "line\n\"quoted\"\\";`},
+		{`"\u{7}bell"`, `"\u{7}bell"`},
+		{`"\u{e9}"`, `"é"`},
+	}
+
+	for _, tt := range tests {
+		p := NewParser(tt.input)
+		prog := p.Parse()
+		checkparseerrors(t, p)
+
+		if prog.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, prog.String())
+		}
+
+		p = NewParser(prog.String())
+		reparsed := p.Parse()
+		checkparseerrors(t, p)
+
+		if reparsed.String() != prog.String() {
+			t.Errorf("round trip changed output. expected=%q, got=%q", prog.String(), reparsed.String())
+		}
+	}
+}
+
+func TestLiteralConstructors(t *testing.T) {
+	pos, end := Position{Line: 1, Column: 3, Offset: 2}, Position{Line: 1, Column: 9, Offset: 8}
+	huge, _ := new(big.Int).SetString("-9223372036854775809", 10)
+
+	tests := []struct {
+		node     Expression
+		expected string
+	}{
+		{NewIntLiteral(big.NewInt(-4), pos, end), "-4"},
+		{NewIntLiteral(huge, pos, end), "-9223372036854775809"},
+		{NewFloatLiteral(3, pos, end), "3.0"},
+		{NewFloatLiteral(0.25, pos, end), "0.25"},
+		{NewFloatLiteral(1e305, pos, end), "1e+305"},
+		{NewFloatLiteral(1.5e-9, pos, end), "1.5e-09"},
+		{NewStringLiteral("a\n", pos, end), `"a\n"`},
+		{NewBoolExpr(true, pos, end), "true"},
+		{NewBoolExpr(false, pos, end), "false"},
+	}
+
+	for _, tt := range tests {
+		if tt.node.String() != tt.expected {
+			t.Errorf("wrong literal. expected=%q, got=%q", tt.expected, tt.node.String())
+		}
+
+		if tt.node.Pos() != pos || tt.node.End() != end {
+			t.Errorf("%s - wrong span. got=%s-%s", tt.expected, tt.node.Pos(), tt.node.End())
+		}
+	}
+
+	if lit := NewIntLiteral(big.NewInt(7), pos, end); lit.Value != 7 || lit.Big != nil {
+		t.Errorf("small integers should be kept in Value. got=%+v", lit)
+	}
+
+	if lit := NewIntLiteral(huge, pos, end); lit.Big == nil {
+		t.Errorf("large integers should be kept in Big. got=%+v", lit)
+	}
+}
+
+func TestIllegalTokens(t *testing.T) {
+	tests := []struct {
+		input    string
+		code     ErrorCode
+		expected string
+		hint     string
+	}{
+		{"let s = \"abc;\nlet t = 1;", ErrUnterminated, "1:9: unterminated string literal", "did you forget a closing `\"`?"},
+		{`let s = "\q"; s`, ErrInvalidEscape, `1:9: unknown escape sequence \q`, ""},
+		{"let a = @;", ErrIllegalToken, `1:9: illegal character "@"`, ""},
+	}
+
+	for _, tt := range tests {
+		p := NewParser(tt.input)
+		p.Parse()
+
+		if len(p.Errors()) != 1 {
+			t.Fatalf("expected 1 error for %q. got=%q", tt.input, p.Errors())
+		}
+
+		err := p.Errors()[0]
+
+		if err.Code != tt.code {
+			t.Errorf("wrong code for %q. expected=%s, got=%s", tt.input, tt.code, err.Code)
+		}
+
+		if err.Error() != tt.expected {
+			t.Errorf("wrong error for %q. expected=%q, got=%q", tt.input, tt.expected, err.Error())
+		}
+
+		if err.Hint != tt.hint {
+			t.Errorf("wrong hint for %q. expected=%q, got=%q", tt.input, tt.hint, err.Hint)
+		}
+	}
+}
+
+func TestProgramComments(t *testing.T) {
+	input := `// add two numbers
+let add = fn(a, b) {
+	a + b /* no overflow checks */
+};
+add(1, 2); // three`
+
+	p := NewParser(input)
+	prog := p.Parse()
+	checkparseerrors(t, p)
+
+	expected := []struct {
+		text string
+		pos  string
+	}{
+		{"// add two numbers", "1:1"},
+		{"/* no overflow checks */", "3:8"},
+		{"// three", "5:12"},
+	}
+
+	if len(prog.Comments) != len(expected) {
+		t.Fatalf("wrong number of comments. expected=%d, got=%d", len(expected), len(prog.Comments))
+	}
+
+	for i, tt := range expected {
+		if prog.Comments[i].Text != tt.text || prog.Comments[i].Pos().String() != tt.pos {
+			t.Errorf("comments[%d] wrong. expected=%q at %s, got=%q at %s", i, tt.text, tt.pos, prog.Comments[i].Text, prog.Comments[i].Pos())
+		}
+	}
+
+	if prog.String() != "let add = fn(a, b) { (a + b) };add(1, 2)" {
+		t.Errorf("comments leaked into the program. got=%q", prog.String())
+	}
+}
+
+func testInfixExpression(t *testing.T, expr Expression, left interface{}, operator string, right interface{}) {
+	infix, ok := expr.(*InfixExpr)
+	if !ok {
+		t.Errorf("expr is not infixexpr. got=%T(%s)", expr, expr)
+		return
+	}
+
+	testLiteralExpression(t, infix.Left, left)
+
+	if infix.Operator != operator {
+		t.Errorf("infix.Operator is not '%s'. got=%q", operator, infix.Operator)
+	}
+
+	testLiteralExpression(t, infix.Right, right)
+}
+
+func testBoolLiteral(t *testing.T, expr Expression, value bool) {
+	bexpr, ok := expr.(*BoolExpr)
 	if !ok {
 		t.Errorf("expr is not boolexpr. got=%T", expr)
 	}
-	if bexpr.value != value {
-		t.Errorf("expr.value is not '%t'. got=%t", value, bexpr.value)
+	if bexpr.Value != value {
+		t.Errorf("expr.Value is not '%t'. got=%t", value, bexpr.Value)
 	}
 	if bexpr.tokenliteral() != fmt.Sprintf("%t", value) {
 		t.Errorf("bexpr.tokenliteral() not %t. got=%s", value, bexpr.tokenliteral())
@@ -430,8 +1501,8 @@ func checkparseerrors(t *testing.T, p *Parser) {
 
 	t.Errorf("parser has %d errors", len(errors))
 
-	for _, msg := range errors {
-		t.Errorf("parser error: %q", msg)
+	for _, err := range errors {
+		t.Errorf("parser error: %q", err.Error())
 	}
 
 	t.FailNow()
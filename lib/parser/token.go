@@ -1,34 +1,60 @@
 package parser
 
-type tokenType string
+import "fmt"
+
+// TokenType is the kind of a token, ParseError uses it to say what the
+// parser expected and what it found.
+type TokenType string
+
+// Position is a location in the source. Line and Column start at 1,
+// Offset is the byte offset from the start of the input.
+type Position struct {
+	Line   int
+	Column int
+	Offset int
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
 
 type token struct {
-	ttype   tokenType
+	ttype   TokenType
 	literal string
+	start   Position
+	end     Position
+	err     *ParseError
+
+	comments []*Comment
 }
 
 const (
-	ILLEGAL = "ILLEGAL"
-	EOF     = "EOF"
+	ILLEGAL TokenType = "ILLEGAL"
+	EOF     TokenType = "EOF"
 
-	IDENT = "IDENT"
-	INT   = "INT"
+	IDENT  TokenType = "IDENT"
+	INT    TokenType = "INT"
+	FLOAT  TokenType = "FLOAT"
+	STRING TokenType = "STRING"
 
-	ASSIGN   = "="
-	PLUS     = "+"
-	MINUS    = "-"
-	ASTERISK = "*"
-	SLASH    = "/"
+	ASSIGN   TokenType = "="
+	PLUS     TokenType = "+"
+	MINUS    TokenType = "-"
+	ASTERISK TokenType = "*"
+	SLASH    TokenType = "/"
 
-	COMMA     = ","
-	SEMICOLON = ";"
-	LPAREN    = "("
-	RPAREN    = ")"
-	LBRACE    = "{"
-	RBRACE    = "}"
-	LT        = "<"
-	GT        = ">"
-	BANG      = "!"
+	COMMA     TokenType = ","
+	SEMICOLON TokenType = ";"
+	COLON     TokenType = ":"
+	LPAREN    TokenType = "("
+	RPAREN    TokenType = ")"
+	LBRACE              = "{"
+	RBRACE              = "}"
+	LBRACKET            = "["
+	RBRACKET            = "]"
+	LT                  = "<"
+	GT                  = ">"
+	BANG                = "!"
 
 	FUNCTION = "FUNCTION"
 	LET      = "LET"
@@ -42,7 +68,7 @@ const (
 	NOTEQ = "!="
 )
 
-var keywords = map[string]tokenType{
+var keywords = map[string]TokenType{
 	"fn":     FUNCTION,
 	"let":    LET,
 	"true":   TRUE,
@@ -52,11 +78,11 @@ var keywords = map[string]tokenType{
 	"return": RETURN,
 }
 
-func newtoken(tokentype tokenType, ch byte) token {
+func newtoken(tokentype TokenType, ch rune) token {
 	return token{ttype: tokentype, literal: string(ch)}
 }
 
-func lookupIdent(ident string) tokenType {
+func lookupIdent(ident string) TokenType {
 	if tok, ok := keywords[ident]; ok {
 		return tok
 	}
@@ -0,0 +1,341 @@
+// Package peephole optimizes compiled functions by looking at a few
+// instructions at a time: it removes values that are pushed only to be
+// popped, threads jumps and fuses common sequences into
+// superinstructions. The optimized code computes the same values and
+// fails with the same errors at the same positions.
+package peephole
+
+import (
+	"github.com/hellozee/monkey/lib/code"
+	"github.com/hellozee/monkey/lib/compiler"
+	"github.com/hellozee/monkey/lib/object"
+	"github.com/hellozee/monkey/lib/parser"
+)
+
+// instruction is a decoded instruction. Jumps refer to the index of
+// their target in the function's list of instructions rather than to an
+// offset, len(list) being the end of the function.
+type instruction struct {
+	op       code.Opcode
+	operands []int
+	pos      parser.Position
+	end      parser.Position
+	removed  bool
+}
+
+func (ins *instruction) isjump() bool {
+	return ins.op == code.OpJump || ins.op == code.OpJumpNotTruthy
+}
+
+// function is the list of instructions of fn being optimized.
+type function struct {
+	fn   *object.CompiledFunction
+	list []*instruction
+}
+
+// pass rewrites the instructions of f and reports whether it changed
+// any. Instead of deleting instructions passes mark them as removed,
+// jumps to a removed instruction go to the next one that is left.
+type pass func(f *function) bool
+
+var passes = []pass{constantbranches, pushpop, threadjumps, unreachable, fuse}
+
+// Optimize rewrites the instructions of every function in bytecode.
+func Optimize(bytecode *compiler.Bytecode) {
+	Function(bytecode.Main)
+	for _, constant := range bytecode.Constants {
+		if fn, ok := constant.(*object.CompiledFunction); ok {
+			Function(fn)
+		}
+	}
+}
+
+// Function rewrites the instructions of fn.
+func Function(fn *object.CompiledFunction) {
+	f := decode(fn)
+
+	// Every change removes an instruction, replaces a jump or points a
+	// jump past another one, so this ends.
+	for changed := true; changed; {
+		changed = false
+		for _, p := range passes {
+			if p(f) {
+				f.compact()
+				changed = true
+			}
+		}
+	}
+
+	f.encode()
+}
+
+func decode(fn *object.CompiledFunction) *function {
+	f := &function{fn: fn}
+	index := make(map[int]int)
+
+	ins := fn.Instructions
+	for offset := 0; offset < len(ins); {
+		def, err := code.Lookup(ins[offset])
+		if err != nil {
+			// Leave code this package doesn't understand alone.
+			return &function{fn: fn}
+		}
+
+		operands, read := code.ReadOperands(def, ins[offset+1:])
+		decoded := &instruction{op: code.Opcode(ins[offset]), operands: operands}
+		if pos, ok := fn.Positions.Lookup(offset); ok {
+			decoded.pos, decoded.end = pos.Pos, pos.End
+		}
+
+		index[offset] = len(f.list)
+		f.list = append(f.list, decoded)
+		offset += 1 + read
+	}
+	index[len(ins)] = len(f.list)
+
+	for _, ins := range f.list {
+		if ins.isjump() {
+			ins.operands[0] = index[ins.operands[0]]
+		}
+	}
+
+	return f
+}
+
+// encode writes the instructions back to the function, with jumps and
+// positions pointing at the new offsets.
+func (f *function) encode() {
+	if f.list == nil {
+		return
+	}
+
+	offsets := make([]int, len(f.list)+1)
+	for i, ins := range f.list {
+		offsets[i+1] = offsets[i] + len(code.Make(ins.op, ins.operands...))
+	}
+
+	var instructions code.Instructions
+	var positions code.Positions
+
+	for i, ins := range f.list {
+		operands := ins.operands
+		if ins.isjump() {
+			operands = []int{offsets[operands[0]]}
+		}
+
+		if n := len(positions); n == 0 || positions[n-1].Pos != ins.pos || positions[n-1].End != ins.end {
+			positions = append(positions, code.Position{Offset: offsets[i], Pos: ins.pos, End: ins.end})
+		}
+		instructions = append(instructions, code.Make(ins.op, operands...)...)
+	}
+
+	f.fn.Instructions = instructions
+	f.fn.Positions = positions
+}
+
+// compact drops removed instructions and moves the jumps to them on to
+// the next instruction that is left.
+func (f *function) compact() {
+	index := make([]int, len(f.list)+1)
+	list := f.list[:0:0]
+
+	for i, ins := range f.list {
+		index[i] = len(list)
+		if !ins.removed {
+			list = append(list, ins)
+		}
+	}
+	index[len(f.list)] = len(list)
+
+	for _, ins := range list {
+		if ins.isjump() {
+			ins.operands[0] = index[ins.operands[0]]
+		}
+	}
+
+	f.list = list
+}
+
+// targets returns which instructions are jumped to.
+func (f *function) targets() map[int]bool {
+	targets := make(map[int]bool)
+	for _, ins := range f.list {
+		if ins.isjump() {
+			targets[ins.operands[0]] = true
+		}
+	}
+	return targets
+}
+
+// at returns the instruction at i, or nil past the end.
+func (f *function) at(i int) *instruction {
+	if i < len(f.list) {
+		return f.list[i]
+	}
+	return nil
+}
+
+// pure reports whether ins pushes a value without any other effect and
+// without a way to fail.
+func (f *function) pure(ins *instruction) bool {
+	switch ins.op {
+	case code.OpConstant, code.OpTrue, code.OpFalse, code.OpNull, code.OpClosure:
+		return true
+	case code.OpGetLocal:
+		return f.parameter(ins.operands[0])
+	}
+	return false
+}
+
+// parameter reports whether local is always set. Parameters get their
+// argument when the function is called, other locals may be read before
+// their let ran and then fall back to a lookup that can fail.
+func (f *function) parameter(local int) bool {
+	return local < f.fn.NumParameters
+}
+
+// constantbranches resolves conditional jumps on values pushed right
+// before them: null and false always jump, every other constant never
+// does.
+func constantbranches(f *function) bool {
+	changed := false
+	targets := f.targets()
+
+	for i, ins := range f.list {
+		next := f.at(i + 1)
+		if ins.removed || next == nil || next.op != code.OpJumpNotTruthy || targets[i+1] {
+			continue
+		}
+
+		switch ins.op {
+		case code.OpFalse, code.OpNull:
+			ins.op, ins.operands = code.OpJump, next.operands
+			next.removed = true
+			changed = true
+		case code.OpTrue, code.OpConstant:
+			ins.removed = true
+			next.removed = true
+			changed = true
+		}
+	}
+
+	return changed
+}
+
+// pushpop removes values that are popped right after being pushed.
+func pushpop(f *function) bool {
+	changed := false
+	targets := f.targets()
+
+	for i, ins := range f.list {
+		next := f.at(i + 1)
+		if ins.removed || next == nil || next.op != code.OpPop || targets[i+1] || !f.pure(ins) {
+			continue
+		}
+
+		ins.removed = true
+		next.removed = true
+		changed = true
+	}
+
+	return changed
+}
+
+// threadjumps makes jumps to an unconditional jump go straight to its
+// target, replaces jumps to a return by the return and removes jumps to
+// the next instruction.
+func threadjumps(f *function) bool {
+	changed := false
+
+	for i, ins := range f.list {
+		if !ins.isjump() {
+			continue
+		}
+
+		// A chain of jumps that comes back on itself never ends, those
+		// are left alone.
+		next, seen := ins.operands[0], map[int]bool{}
+		for target := f.at(next); target != nil && target.op == code.OpJump && !seen[next]; target = f.at(next) {
+			seen[next] = true
+			next = target.operands[0]
+		}
+		if target := f.at(next); next != ins.operands[0] && (target == nil || target.op != code.OpJump) {
+			ins.operands[0] = next
+			changed = true
+		}
+
+		target := f.at(ins.operands[0])
+		switch {
+		case ins.operands[0] == i+1 && ins.op == code.OpJump:
+			ins.removed = true
+			changed = true
+		case ins.operands[0] == i+1:
+			// The condition still has to be popped.
+			ins.op, ins.operands = code.OpPop, nil
+			changed = true
+		case ins.op == code.OpJump && target != nil && (target.op == code.OpReturnValue || target.op == code.OpReturn):
+			ins.op, ins.operands = target.op, nil
+			changed = true
+		}
+	}
+
+	return changed
+}
+
+// unreachable removes the instructions after an unconditional jump or a
+// return that no jump goes to.
+func unreachable(f *function) bool {
+	changed := false
+	targets := f.targets()
+
+	dead := false
+	for i, ins := range f.list {
+		if targets[i] {
+			dead = false
+		}
+
+		if dead {
+			ins.removed = true
+			changed = true
+			continue
+		}
+
+		switch ins.op {
+		case code.OpJump, code.OpReturnValue, code.OpReturn:
+			dead = true
+		}
+	}
+
+	return changed
+}
+
+// fuse turns a parameter, a constant and an infix operator on them into
+// one OpInfixLocalConstant, which is how most arithmetic and comparisons
+// on arguments look. The fused instruction takes the position of the
+// operator, the only part that can fail.
+func fuse(f *function) bool {
+	changed := false
+	targets := f.targets()
+
+	for i, ins := range f.list {
+		constant, operator := f.at(i+1), f.at(i+2)
+		if ins.removed || ins.op != code.OpGetLocal || !f.parameter(ins.operands[0]) ||
+			constant == nil || constant.op != code.OpConstant || targets[i+1] ||
+			operator == nil || targets[i+2] {
+			continue
+		}
+
+		if _, ok := code.InfixOperators[operator.op]; !ok {
+			continue
+		}
+
+		ins.op = code.OpInfixLocalConstant
+		ins.operands = []int{ins.operands[0], constant.operands[0], int(operator.op)}
+		ins.pos, ins.end = operator.pos, operator.end
+		constant.removed = true
+		operator.removed = true
+		changed = true
+	}
+
+	return changed
+}
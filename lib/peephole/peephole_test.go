@@ -0,0 +1,286 @@
+package peephole
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/hellozee/monkey/lib/code"
+	"github.com/hellozee/monkey/lib/compiler"
+	"github.com/hellozee/monkey/lib/conformance"
+	"github.com/hellozee/monkey/lib/object"
+	"github.com/hellozee/monkey/lib/optimizer"
+	"github.com/hellozee/monkey/lib/parser"
+	"github.com/hellozee/monkey/lib/vm"
+)
+
+type peepholetestcase struct {
+	input string
+	// function is the index of the constant to check, -1 for the main
+	// program.
+	function     int
+	instructions []code.Instructions
+}
+
+func TestPushPop(t *testing.T) {
+	tests := []peepholetestcase{
+		{
+			input:    "1; true; 2",
+			function: -1,
+			instructions: []code.Instructions{
+				code.Make(code.OpConstant, 1),
+			},
+		},
+		{
+			input:    "x; 1",
+			function: -1,
+			instructions: []code.Instructions{
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 0),
+			},
+		},
+		{
+			input:    "fn(a) { a; fn() {}; a }",
+			function: 1,
+			instructions: []code.Instructions{
+				code.Make(code.OpGetLocal, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			// b may be read before its let and fail.
+			input:    "fn() { b; let b = 1; }",
+			function: 1,
+			instructions: []code.Instructions{
+				code.Make(code.OpGetLocal, 0),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetLocal, 0),
+				code.Make(code.OpReturn),
+			},
+		},
+	}
+
+	runpeepholetests(t, tests)
+}
+
+func TestConstantBranches(t *testing.T) {
+	tests := []peepholetestcase{
+		{
+			input:    "if (true) { 10 }; 3333",
+			function: -1,
+			instructions: []code.Instructions{
+				code.Make(code.OpConstant, 1),
+			},
+		},
+		{
+			input:    "if (false) { 10 } else { 20 }",
+			function: -1,
+			instructions: []code.Instructions{
+				code.Make(code.OpConstant, 1),
+			},
+		},
+		{
+			input:    `if ("yes") { x }`,
+			function: -1,
+			instructions: []code.Instructions{
+				code.Make(code.OpGetGlobal, 0),
+			},
+		},
+	}
+
+	runpeepholetests(t, tests)
+}
+
+func TestThreadJumps(t *testing.T) {
+	tests := []peepholetestcase{
+		{
+			input:    "fn(n) { if (n < 2) { n } else { 2 } }",
+			function: 2,
+			instructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpInfixLocalConstant, 0, 0, int(code.OpLessThan)),
+				// 0006
+				code.Make(code.OpJumpNotTruthy, 13),
+				// 0009
+				code.Make(code.OpGetLocal, 0),
+				// 0012
+				code.Make(code.OpReturnValue),
+				// 0013
+				code.Make(code.OpConstant, 1),
+				// 0016
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runpeepholetests(t, tests)
+
+	// The compiler doesn't produce chains of jumps on its own.
+	fn := &object.CompiledFunction{Instructions: concat([]code.Instructions{
+		// 0000
+		code.Make(code.OpGetGlobal, 0),
+		// 0003
+		code.Make(code.OpJumpNotTruthy, 12),
+		// 0006
+		code.Make(code.OpGetGlobal, 1),
+		// 0009
+		code.Make(code.OpJump, 18),
+		// 0012
+		code.Make(code.OpJump, 18),
+		// 0015
+		code.Make(code.OpGetGlobal, 2),
+		// 0018
+		code.Make(code.OpPop),
+	})}
+
+	Function(fn)
+
+	expected := concat([]code.Instructions{
+		code.Make(code.OpGetGlobal, 0),
+		code.Make(code.OpJumpNotTruthy, 9),
+		code.Make(code.OpGetGlobal, 1),
+		code.Make(code.OpPop),
+	})
+	if !bytes.Equal(fn.Instructions, expected) {
+		t.Errorf("jumps not threaded.\nwant=\n%s\ngot=\n%s", expected, fn.Instructions)
+	}
+
+	loop := concat([]code.Instructions{
+		code.Make(code.OpJump, 3),
+		code.Make(code.OpJump, 0),
+	})
+	fn = &object.CompiledFunction{Instructions: loop}
+
+	Function(fn)
+
+	// The first jump only goes to the next instruction, what is left has
+	// to loop forever too.
+	if expected := code.Instructions(code.Make(code.OpJump, 0)); !bytes.Equal(fn.Instructions, expected) {
+		t.Errorf("a loop of jumps was broken.\nwant=\n%s\ngot=\n%s", expected, fn.Instructions)
+	}
+}
+
+func TestFuse(t *testing.T) {
+	tests := []peepholetestcase{
+		{
+			input:    "fn(n) { n - 1 }",
+			function: 1,
+			instructions: []code.Instructions{
+				code.Make(code.OpInfixLocalConstant, 0, 0, int(code.OpSub)),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:    "fn(n) { 1 - n }",
+			function: 1,
+			instructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpGetLocal, 0),
+				code.Make(code.OpSub),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:    "fn() { let a = 1; a + 2 }",
+			function: 2,
+			instructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetLocal, 0),
+				code.Make(code.OpGetLocal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runpeepholetests(t, tests)
+}
+
+func TestPositions(t *testing.T) {
+	bytecode := compile(t, "fn(n) {\n  let x = n;\n  n + 1\n}")
+	Optimize(bytecode)
+
+	fn := bytecode.Constants[1].(*object.CompiledFunction)
+	pos, ok := fn.Positions.Lookup(6)
+	if !ok {
+		t.Fatalf("no position for the fused instruction")
+	}
+
+	if pos.Pos.String() != "3:3" || pos.End.String() != "3:8" {
+		t.Errorf("wrong position. expected=3:3-3:8, got=%s-%s", pos.Pos, pos.End)
+	}
+}
+
+// The conformance suite runs on bytecode optimized on its own and after
+// the AST optimizer, like monkey build does.
+
+func TestConformance(t *testing.T) {
+	conformance.Run(t, func(prog *parser.Program, builtins *object.Builtins) object.Object {
+		return run(t, prog, builtins)
+	})
+}
+
+func TestConformanceOptimized(t *testing.T) {
+	conformance.Run(t, func(prog *parser.Program, builtins *object.Builtins) object.Object {
+		return run(t, optimizer.Optimize(prog, optimizer.Full), builtins)
+	})
+}
+
+func run(t *testing.T, prog *parser.Program, builtins *object.Builtins) object.Object {
+	c := compiler.New()
+	if err := c.Compile(prog); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	bytecode := c.Bytecode()
+	Optimize(bytecode)
+
+	machine := vm.New(bytecode)
+	machine.SetBuiltins(builtins)
+	return machine.Run()
+}
+
+func compile(t *testing.T, input string) *compiler.Bytecode {
+	t.Helper()
+
+	p := parser.NewParser(input)
+	prog := p.Parse()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %s", p.Errors())
+	}
+
+	c := compiler.New()
+	if err := c.Compile(prog); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	return c.Bytecode()
+}
+
+func runpeepholetests(t *testing.T, tests []peepholetestcase) {
+	t.Helper()
+
+	for _, tt := range tests {
+		bytecode := compile(t, tt.input)
+		Optimize(bytecode)
+
+		fn := bytecode.Main
+		if tt.function >= 0 {
+			fn = bytecode.Constants[tt.function].(*object.CompiledFunction)
+		}
+
+		expected := concat(tt.instructions)
+		if !bytes.Equal(fn.Instructions, expected) {
+			t.Errorf("%q - wrong instructions.\nwant=\n%s\ngot=\n%s", tt.input, expected, fn.Instructions)
+		}
+	}
+}
+
+func concat(s []code.Instructions) code.Instructions {
+	out := code.Instructions{}
+	for _, ins := range s {
+		out = append(out, ins...)
+	}
+	return out
+}
@@ -0,0 +1,167 @@
+package repl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/hellozee/monkey/lib/diagnostic"
+	"github.com/hellozee/monkey/lib/evaluator"
+	"github.com/hellozee/monkey/lib/object"
+	"github.com/hellozee/monkey/lib/parser"
+)
+
+const (
+	PROMPT       = ">> "
+	CONTINUATION = ".. "
+)
+
+const help = `Type a Monkey expression or statement to evaluate it.
+Unbalanced braces or parentheses continue the input on the next line.
+
+Commands:
+  :help     show this message
+  :history  list previously entered input
+  :quit     leave the REPL
+`
+
+type repl struct {
+	out     io.Writer
+	env     *object.Environment
+	history []string
+}
+
+func Start(in io.Reader, out io.Writer) {
+	r := &repl{out: out, env: object.NewEnvironment()}
+	r.env.SetBuiltins(object.DefaultBuiltins(out))
+	scanner := bufio.NewScanner(in)
+
+	var buffer strings.Builder
+
+	for {
+		if buffer.Len() == 0 {
+			fmt.Fprint(out, PROMPT)
+		} else {
+			fmt.Fprint(out, CONTINUATION)
+		}
+
+		if !scanner.Scan() {
+			fmt.Fprintln(out)
+			return
+		}
+
+		line := scanner.Text()
+
+		if buffer.Len() == 0 && strings.HasPrefix(strings.TrimSpace(line), ":") {
+			if !r.command(strings.TrimSpace(line)) {
+				return
+			}
+			continue
+		}
+
+		buffer.WriteString(line)
+		buffer.WriteString("\n")
+
+		if unbalanced(buffer.String()) {
+			continue
+		}
+
+		input := buffer.String()
+		buffer.Reset()
+
+		if strings.TrimSpace(input) == "" {
+			continue
+		}
+
+		r.history = append(r.history, strings.TrimRight(input, "\n"))
+		r.eval(input)
+	}
+}
+
+func (r *repl) command(cmd string) bool {
+	switch cmd {
+	case ":quit", ":q":
+		return false
+	case ":help", ":h":
+		fmt.Fprint(r.out, help)
+	case ":history":
+		for i, entry := range r.history {
+			fmt.Fprintf(r.out, "%4d  %s\n", i+1, entry)
+		}
+	default:
+		fmt.Fprintf(r.out, "unknown command %s, type :help for a list of commands\n", cmd)
+	}
+	return true
+}
+
+func (r *repl) eval(input string) {
+	p := parser.NewParser(input)
+	prog := p.Parse()
+	printer := diagnostic.NewPrinter("repl", input, false)
+
+	if len(p.Errors()) != 0 {
+		printer.PrintAll(r.out, p.Errors())
+		return
+	}
+
+	evaluated := evaluator.Eval(prog, r.env)
+	if errobj, ok := evaluated.(*object.Error); ok {
+		printer.Print(r.out, diagnostic.FromRuntimeError(errobj))
+		return
+	}
+
+	if evaluated != nil {
+		fmt.Fprintln(r.out, evaluated.Inspect())
+	}
+}
+
+// unbalanced reports whether input has open brackets of any kind, an open
+// string literal or block comment, in which case the REPL waits for more
+// lines. Braces inside strings and comments don't count.
+func unbalanced(input string) bool {
+	depth := 0
+	comments := 0
+	instring := false
+
+	for i := 0; i < len(input); i++ {
+		char := input[i]
+		next := byte(0)
+		if i+1 < len(input) {
+			next = input[i+1]
+		}
+
+		switch {
+		case comments > 0:
+			if char == '/' && next == '*' {
+				comments++
+				i++
+			} else if char == '*' && next == '/' {
+				comments--
+				i++
+			}
+		case instring:
+			switch char {
+			case '\\':
+				i++
+			case '"':
+				instring = false
+			}
+		case char == '/' && next == '/':
+			for i < len(input) && input[i] != '\n' {
+				i++
+			}
+		case char == '/' && next == '*':
+			comments++
+			i++
+		case char == '"':
+			instring = true
+		case char == '{' || char == '(' || char == '[':
+			depth++
+		case char == '}' || char == ')' || char == ']':
+			depth--
+		}
+	}
+
+	return instring || comments > 0 || depth > 0
+}
@@ -0,0 +1,117 @@
+package repl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func run(input string) string {
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out)
+	return out.String()
+}
+
+func TestEnvironmentPersists(t *testing.T) {
+	output := run("let x = 5;\nlet y = x * 2;\ny + 1\n")
+	expected := ">> >> >> 11\n>> \n"
+
+	if output != expected {
+		t.Errorf("wrong output. expected=%q, got=%q", expected, output)
+	}
+}
+
+func TestPutsWritesToOutput(t *testing.T) {
+	output := run("puts(\"hi\", len([1, 2]))\n")
+	expected := ">> hi\n2\nnull\n>> \n"
+
+	if output != expected {
+		t.Errorf("wrong output. expected=%q, got=%q", expected, output)
+	}
+}
+
+func TestMultilineInput(t *testing.T) {
+	output := run("let add = fn(a, b) {\n  a + b\n};\nadd(2, 3)\n")
+	expected := ">> .. .. >> 5\n>> \n"
+
+	if output != expected {
+		t.Errorf("wrong output. expected=%q, got=%q", expected, output)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	output := run("let = 5;\n")
+
+	expected := "error[E0001]: expected next token is IDENT, got = instead\n" +
+		" --> repl:1:5\n" +
+		"  |\n" +
+		"1 | let = 5;\n" +
+		"  |     ^\n"
+
+	if !strings.Contains(output, expected) {
+		t.Errorf("output does not contain the parse error. expected=%q, got=%q", expected, output)
+	}
+}
+
+func TestRuntimeErrors(t *testing.T) {
+	output := run("let x = 1;\nx + true\n")
+
+	expected := "error: type mismatch: INTEGER + BOOLEAN\n" +
+		" --> repl:1:1\n" +
+		"  |\n" +
+		"1 | x + true\n" +
+		"  | ^^^^^^^^\n"
+
+	if !strings.Contains(output, expected) {
+		t.Errorf("output does not contain the runtime error. expected=%q, got=%q", expected, output)
+	}
+}
+
+func TestCommands(t *testing.T) {
+	output := run("1 + 1\n:history\n:help\n:bogus\n:quit\n2 + 2\n")
+
+	if !strings.Contains(output, "   1  1 + 1\n") {
+		t.Errorf(":history did not list previous input. got=%q", output)
+	}
+
+	if !strings.Contains(output, ":quit     leave the REPL") {
+		t.Errorf(":help did not print the command list. got=%q", output)
+	}
+
+	if !strings.Contains(output, "unknown command :bogus") {
+		t.Errorf("unknown command not reported. got=%q", output)
+	}
+
+	if strings.Contains(output, "4\n") {
+		t.Errorf("input after :quit was evaluated. got=%q", output)
+	}
+}
+
+func TestUnbalanced(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"let x = 5;", false},
+		{"fn(x) {", true},
+		{"fn(x) { x }", false},
+		{"add(1,", true},
+		{"}", false},
+		{`puts("{")`, false},
+		{`let s = "a \" {`, true},
+		{`let s = "a \\" + fn() {`, true},
+		{"let x = 1; // {", false},
+		{"// (\nfn() {", true},
+		{"/* { /* } */", true},
+		{"/* { /* } */ */ 1", false},
+		{"let d = 4 / 2 * (1", true},
+		{"let a = [1,", true},
+		{"let a = [1, 2][0:1]", false},
+	}
+
+	for _, tt := range tests {
+		if unbalanced(tt.input) != tt.expected {
+			t.Errorf("unbalanced(%q) wrong. expected=%t", tt.input, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/hellozee/monkey/lib/compiler"
+	"github.com/hellozee/monkey/lib/evaluator"
+	"github.com/hellozee/monkey/lib/object"
+	"github.com/hellozee/monkey/lib/optimizer"
+	"github.com/hellozee/monkey/lib/parser"
+	"github.com/hellozee/monkey/lib/peephole"
+)
+
+// Monkey has no loop statement, loops are written as tail calls.
+var benchmarks = []struct {
+	name     string
+	input    string
+	expected string
+}{
+	{
+		name:     "fib",
+		input:    "let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(20)",
+		expected: "6765",
+	},
+	{
+		name:     "loop",
+		input:    "let loop = fn(i, sum) { if (i == 0) { sum } else { loop(i - 1, sum + i * 2) } }; loop(1000, 0)",
+		expected: "1001000",
+	},
+}
+
+// BenchmarkPrograms compares the evaluator to the VM, with and without
+// the optimizers, on every program. Only running the program is timed.
+func BenchmarkPrograms(b *testing.B) {
+	for _, bm := range benchmarks {
+		b.Run(bm.name+"/evaluator", func(b *testing.B) {
+			prog := parse(b, bm.input)
+			for i := 0; i < b.N; i++ {
+				result := evaluator.Eval(prog, object.NewEnvironment())
+				check(b, result, bm.expected)
+			}
+		})
+
+		b.Run(bm.name+"/vm", func(b *testing.B) {
+			benchmarkvm(b, bm.input, bm.expected, optimizer.None)
+		})
+
+		b.Run(bm.name+"/vm-optimized", func(b *testing.B) {
+			benchmarkvm(b, bm.input, bm.expected, optimizer.Full)
+		})
+	}
+}
+
+func benchmarkvm(b *testing.B, input, expected string, level optimizer.Level) {
+	c := compiler.New()
+	if err := c.Compile(optimizer.Optimize(parse(b, input), level)); err != nil {
+		b.Fatalf("compiler error: %s", err)
+	}
+
+	bytecode := c.Bytecode()
+	if level >= optimizer.Full {
+		peephole.Optimize(bytecode)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		check(b, New(bytecode).Run(), expected)
+	}
+}
+
+func parse(b *testing.B, input string) *parser.Program {
+	p := parser.NewParser(input)
+	prog := p.Parse()
+	if len(p.Errors()) != 0 {
+		b.Fatalf("parser errors: %s", p.Errors())
+	}
+	return prog
+}
+
+func check(b *testing.B, result object.Object, expected string) {
+	if result == nil || result.Inspect() != expected {
+		b.Fatalf("wrong result. expected=%s, got=%v", expected, result)
+	}
+}
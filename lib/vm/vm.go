@@ -0,0 +1,367 @@
+package vm
+
+import (
+	"os"
+
+	"github.com/hellozee/monkey/lib/code"
+	"github.com/hellozee/monkey/lib/compiler"
+	"github.com/hellozee/monkey/lib/evaluator"
+	"github.com/hellozee/monkey/lib/object"
+)
+
+// The stack and the frames start this big and grow as needed, calls
+// can nest up to evaluator.MaxDepth deep like in the evaluator.
+const (
+	stacksize = 2048
+	framesize = 1024
+)
+
+// scope holds the locals of one function call. Closures keep the scope
+// they were created in, so like the evaluator's environments a variable
+// is shared rather than copied.
+type scope struct {
+	slots []object.Object
+	fn    *object.CompiledFunction
+	outer *scope
+}
+
+type closure struct {
+	fn    *object.CompiledFunction
+	scope *scope
+}
+
+// closures are FUNCTION to scripts, same as functions in the evaluator.
+func (c *closure) Type() object.ObjectType { return object.FUNCTION }
+func (c *closure) Inspect() string         { return c.fn.Source }
+
+type frame struct {
+	cl     *closure
+	locals *scope
+	ip     int
+	bp     int
+}
+
+type VM struct {
+	constants   []object.Object
+	globals     []object.Object
+	globalnames []string
+	globalindex map[string]int
+	builtins    *object.Builtins
+
+	stack []object.Object
+	sp    int
+
+	frames      []*frame
+	framesindex int
+}
+
+// New returns a VM for bytecode with the default builtins, puts writes
+// to stdout.
+func New(bytecode *compiler.Bytecode) *VM {
+	frames := make([]*frame, 1, framesize)
+	frames[0] = &frame{cl: &closure{fn: bytecode.Main}}
+
+	return &VM{
+		constants:   bytecode.Constants,
+		globals:     make([]object.Object, len(bytecode.Globals)),
+		globalnames: bytecode.Globals,
+		builtins:    object.DefaultBuiltins(os.Stdout),
+		stack:       make([]object.Object, stacksize),
+		frames:      frames,
+		framesindex: 1,
+	}
+}
+
+func (vm *VM) SetBuiltins(b *object.Builtins) {
+	vm.builtins = b
+}
+
+// SetGlobal gives the global called name a value before the program
+// runs, it does nothing when the program doesn't use name.
+func (vm *VM) SetGlobal(name string, value object.Object) {
+	for i, n := range vm.globalnames {
+		if n == name {
+			vm.globals[i] = value
+		}
+	}
+}
+
+// Run executes the program and returns its value like evaluator.Eval
+// does: the value of the last expression statement or a return, nil
+// when the program is empty or ends in a let, and an *object.Error when
+// it fails.
+func (vm *VM) Run() object.Object {
+	for {
+		f := vm.frames[vm.framesindex-1]
+		ins := f.cl.fn.Instructions
+
+		if f.ip >= len(ins) {
+			if vm.sp > 0 {
+				return vm.stack[vm.sp-1]
+			}
+			return nil
+		}
+
+		start := f.ip
+		op := code.Opcode(ins[f.ip])
+		f.ip++
+
+		var result object.Object
+
+		switch op {
+		case code.OpConstant:
+			result = vm.push(vm.constants[code.ReadUint16(ins[f.ip:])])
+			f.ip += 2
+		case code.OpPop:
+			vm.pop()
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv,
+			code.OpEqual, code.OpNotEqual, code.OpGreaterThan, code.OpLessThan:
+			right := vm.pop()
+			left := vm.pop()
+			result = vm.push(evaluator.Infix(code.InfixOperators[op], left, right))
+		case code.OpMinus:
+			result = vm.push(evaluator.Prefix("-", vm.pop()))
+		case code.OpBang:
+			result = vm.push(evaluator.Prefix("!", vm.pop()))
+		case code.OpTrue:
+			result = vm.push(evaluator.TRUE)
+		case code.OpFalse:
+			result = vm.push(evaluator.FALSE)
+		case code.OpNull:
+			result = vm.push(evaluator.NULL)
+		case code.OpJump:
+			f.ip = int(code.ReadUint16(ins[f.ip:]))
+		case code.OpJumpNotTruthy:
+			target := int(code.ReadUint16(ins[f.ip:]))
+			f.ip += 2
+			if !evaluator.Truthy(vm.pop()) {
+				f.ip = target
+			}
+		case code.OpSetGlobal:
+			vm.globals[code.ReadUint16(ins[f.ip:])] = vm.pop()
+			f.ip += 2
+		case code.OpGetGlobal:
+			index := code.ReadUint16(ins[f.ip:])
+			f.ip += 2
+			value := vm.globals[index]
+			if value == nil {
+				value = vm.lookup(vm.globalnames[index], nil)
+			}
+			result = vm.push(value)
+		case code.OpSetLocal:
+			f.locals.slots[code.ReadUint16(ins[f.ip:])] = vm.pop()
+			f.ip += 2
+		case code.OpGetLocal:
+			index := code.ReadUint16(ins[f.ip:])
+			f.ip += 2
+			result = vm.push(vm.local(f.locals, int(index)))
+		case code.OpGetOuter:
+			depth := code.ReadUint8(ins[f.ip:])
+			index := code.ReadUint16(ins[f.ip+1:])
+			f.ip += 3
+			s := f.locals
+			for i := uint8(0); i < depth; i++ {
+				s = s.outer
+			}
+			result = vm.push(vm.local(s, int(index)))
+		case code.OpInfixLocalConstant:
+			local := vm.local(f.locals, int(code.ReadUint16(ins[f.ip:])))
+			constant := vm.constants[code.ReadUint16(ins[f.ip+2:])]
+			operator := code.InfixOperators[code.Opcode(ins[f.ip+4])]
+			f.ip += 5
+			if errobj, ok := local.(*object.Error); ok {
+				result = errobj
+				break
+			}
+			result = vm.push(evaluator.Infix(operator, local, constant))
+		case code.OpArray:
+			n := int(code.ReadUint16(ins[f.ip:]))
+			f.ip += 2
+			elements := make([]object.Object, n)
+			copy(elements, vm.stack[vm.sp-n:vm.sp])
+			vm.sp -= n
+			result = vm.push(&object.Array{Elements: elements})
+		case code.OpHashKey:
+			if _, err := evaluator.HashKey(vm.stack[vm.sp-1]); err != nil {
+				result = err
+			}
+		case code.OpHash:
+			n := int(code.ReadUint16(ins[f.ip:]))
+			f.ip += 2
+			result = vm.push(vm.buildhash(n))
+		case code.OpIndex:
+			index := vm.pop()
+			left := vm.pop()
+			result = vm.push(evaluator.Index(left, index))
+		case code.OpSlice:
+			flags := code.ReadUint8(ins[f.ip:])
+			f.ip++
+			var low, high object.Object
+			if flags&code.SliceHigh != 0 {
+				high = vm.pop()
+			}
+			if flags&code.SliceLow != 0 {
+				low = vm.pop()
+			}
+			left := vm.pop()
+			result = vm.push(evaluator.Slice(left, low, high))
+		case code.OpClosure:
+			fn := vm.constants[code.ReadUint16(ins[f.ip:])].(*object.CompiledFunction)
+			f.ip += 2
+			result = vm.push(&closure{fn: fn, scope: f.locals})
+		case code.OpCall:
+			argc := int(code.ReadUint8(ins[f.ip:]))
+			f.ip++
+			result = vm.call(argc)
+		case code.OpReturnValue, code.OpReturn:
+			value := object.Object(evaluator.NULL)
+			if op == code.OpReturnValue {
+				value = vm.pop()
+			}
+
+			if vm.framesindex == 1 {
+				return value
+			}
+
+			vm.framesindex--
+			vm.sp = f.bp
+			result = vm.push(value)
+		}
+
+		if errobj, ok := result.(*object.Error); ok {
+			return vm.locate(errobj, f, start)
+		}
+	}
+}
+
+// push returns value when it is an error, so the result of an operation
+// can be pushed without checking it first.
+func (vm *VM) push(value object.Object) object.Object {
+	if errobj, ok := value.(*object.Error); ok {
+		return errobj
+	}
+
+	if vm.sp == len(vm.stack) {
+		vm.stack = append(vm.stack, value)
+	} else {
+		vm.stack[vm.sp] = value
+	}
+	vm.sp++
+	return nil
+}
+
+func (vm *VM) pop() object.Object {
+	vm.sp--
+	return vm.stack[vm.sp]
+}
+
+func (vm *VM) call(argc int) object.Object {
+	callee := vm.stack[vm.sp-1-argc]
+
+	switch callee := callee.(type) {
+	case *closure:
+		if argc != callee.fn.NumParameters {
+			return object.NewError("wrong number of arguments: want=%d, got=%d", callee.fn.NumParameters, argc)
+		}
+
+		if vm.framesindex > evaluator.MaxDepth {
+			return object.NewError("stack overflow")
+		}
+
+		locals := &scope{slots: make([]object.Object, len(callee.fn.Locals)), fn: callee.fn, outer: callee.scope}
+		copy(locals.slots, vm.stack[vm.sp-argc:vm.sp])
+		vm.sp -= argc + 1
+
+		f := &frame{cl: callee, locals: locals, bp: vm.sp}
+		if vm.framesindex == len(vm.frames) {
+			vm.frames = append(vm.frames, f)
+		} else {
+			vm.frames[vm.framesindex] = f
+		}
+		vm.framesindex++
+		return nil
+	case *object.Builtin:
+		args := make([]object.Object, argc)
+		copy(args, vm.stack[vm.sp-argc:vm.sp])
+		vm.sp -= argc + 1
+
+		result := callee.Call(args...)
+		if result == nil {
+			result = evaluator.NULL
+		}
+		return vm.push(result)
+	default:
+		return object.NewError("not a function: %s", callee.Type())
+	}
+}
+
+func (vm *VM) buildhash(n int) object.Object {
+	pairs := make(map[object.HashKey]object.HashPair, n)
+
+	for i := vm.sp - 2*n; i < vm.sp; i += 2 {
+		key, value := vm.stack[i], vm.stack[i+1]
+		hashkey, err := evaluator.HashKey(key)
+		if err != nil {
+			return err
+		}
+		pairs[hashkey] = object.HashPair{Key: key, Value: value}
+	}
+
+	vm.sp -= 2 * n
+	return &object.Hash{Pairs: pairs}
+}
+
+// local reads a slot of s, a slot that wasn't set yet is looked up by
+// name further out like an environment that doesn't have the name.
+func (vm *VM) local(s *scope, index int) object.Object {
+	if value := s.slots[index]; value != nil {
+		return value
+	}
+	return vm.lookup(s.fn.Locals[index], s.outer)
+}
+
+// lookup searches name in the scopes from s outwards, then among the
+// globals and builtins. It is the slow path for variables read before
+// their let ran.
+func (vm *VM) lookup(name string, s *scope) object.Object {
+	for ; s != nil; s = s.outer {
+		for i := len(s.fn.Locals) - 1; i >= 0; i-- {
+			if s.fn.Locals[i] == name && s.slots[i] != nil {
+				return s.slots[i]
+			}
+		}
+	}
+
+	if vm.globalindex == nil {
+		vm.globalindex = make(map[string]int, len(vm.globalnames))
+		for i, n := range vm.globalnames {
+			vm.globalindex[n] = i
+		}
+	}
+
+	if i, ok := vm.globalindex[name]; ok && vm.globals[i] != nil {
+		return vm.globals[i]
+	}
+
+	if vm.builtins != nil {
+		if builtin, ok := vm.builtins.Lookup(name); ok {
+			return builtin
+		}
+	}
+
+	return object.NewError("identifier not found: %s", name)
+}
+
+// locate gives errors the position of the instruction at offset in f,
+// unless they already have one.
+func (vm *VM) locate(errobj *object.Error, f *frame, offset int) *object.Error {
+	if errobj.Pos.Line != 0 {
+		return errobj
+	}
+
+	if pos, ok := f.cl.fn.Positions.Lookup(offset); ok {
+		errobj.Pos = pos.Pos
+		errobj.End = pos.End
+	}
+	return errobj
+}
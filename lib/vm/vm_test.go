@@ -0,0 +1,101 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/hellozee/monkey/lib/compiler"
+	"github.com/hellozee/monkey/lib/conformance"
+	"github.com/hellozee/monkey/lib/object"
+	"github.com/hellozee/monkey/lib/parser"
+)
+
+func TestConformance(t *testing.T) {
+	conformance.Run(t, func(prog *parser.Program, builtins *object.Builtins) object.Object {
+		c := compiler.New()
+		if err := c.Compile(prog); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		machine := New(c.Bytecode())
+		machine.SetBuiltins(builtins)
+		return machine.Run()
+	})
+}
+
+func run(t *testing.T, input string) object.Object {
+	t.Helper()
+
+	p := parser.NewParser(input)
+	prog := p.Parse()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %s", p.Errors())
+	}
+
+	c := compiler.New()
+	if err := c.Compile(prog); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	return New(c.Bytecode()).Run()
+}
+
+func TestStackOverflow(t *testing.T) {
+	tests := []string{
+		"let f = fn(n) { f(n + 1) }; f(0)",
+		"let f = fn(n) { 1 + f(n + 1) }; f(0)",
+	}
+
+	for _, input := range tests {
+		errobj, ok := run(t, input).(*object.Error)
+		if !ok {
+			t.Fatalf("%q - no error returned", input)
+		}
+
+		if errobj.Message != "stack overflow" {
+			t.Errorf("%q - wrong error. got=%q", input, errobj.Message)
+		}
+	}
+}
+
+func TestDeepRecursion(t *testing.T) {
+	input := "let count = fn(n) { if (n == 0) { 0 } else { 1 + count(n - 1) } }; count(1000)"
+
+	if result := run(t, input); result.Inspect() != "1000" {
+		t.Errorf("wrong result. got=%s", result.Inspect())
+	}
+}
+
+func TestStackIsBalanced(t *testing.T) {
+	input := `let f = fn(x) { if (x) { 1 } }; f(true); f(false); [1, 2][0:1]; {"a": 1}["a"]; len([]); let z = 1;`
+
+	p := parser.NewParser(input)
+	c := compiler.New()
+	if err := c.Compile(p.Parse()); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	machine := New(c.Bytecode())
+	if result := machine.Run(); result != nil {
+		t.Fatalf("expected no value. got=%s", result.Inspect())
+	}
+
+	if machine.sp != 0 {
+		t.Errorf("stack not empty after the program. sp=%d", machine.sp)
+	}
+}
+
+func TestSetGlobal(t *testing.T) {
+	p := parser.NewParser("let f = fn() { args[0] }; f()")
+	c := compiler.New()
+	if err := c.Compile(p.Parse()); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	machine := New(c.Bytecode())
+	machine.SetGlobal("args", &object.Array{Elements: []object.Object{&object.String{Value: "first"}}})
+	machine.SetGlobal("unused", &object.Integer{Value: 1})
+
+	if result := machine.Run(); result == nil || result.Inspect() != "first" {
+		t.Errorf("wrong result. got=%v", result)
+	}
+}